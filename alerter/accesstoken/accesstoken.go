@@ -0,0 +1,173 @@
+// Package accesstoken implements HMAC-based access tokens used to gate the
+// alerter RPC endpoints, so that holding the node's RPC socket is not
+// sufficient to register destinations or send alerts on someone else's
+// behalf.
+package accesstoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	tokensKey  []byte = []byte("geth-alerter-accesstokens")
+	hmacKeyKey []byte = []byte("geth-alerter-accesstoken-hmackey")
+)
+
+// storedToken is the RLP-encodable, on-disk representation of a token.
+// Only the HMAC of the secret is ever written to disk.
+type storedToken struct {
+	Name       string
+	SecretHash []byte
+	Scopes     []string
+	CreatedAt  int64
+}
+
+// TokenInfo is the secret-free, public view of a registered token
+type TokenInfo struct {
+	Name      string
+	Scopes    []string
+	CreatedAt int64
+}
+
+// Manager creates, checks and revokes access tokens, persisting them to db
+type Manager struct {
+	db      ethdb.Database
+	hmacKey []byte
+}
+
+// NewManager returns a Manager backed by db, generating and persisting a
+// fresh HMAC key the first time it is used
+func NewManager(db ethdb.Database) (*Manager, error) {
+	m := &Manager{db: db}
+	key, err := m.loadOrCreateHMACKey()
+	if err != nil {
+		return nil, err
+	}
+	m.hmacKey = key
+	return m, nil
+}
+
+func (m *Manager) loadOrCreateHMACKey() ([]byte, error) {
+	if key, err := m.db.Get(hmacKeyKey); err == nil && len(key) > 0 {
+		return key, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := m.db.Put(hmacKeyKey, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (m *Manager) hash(secret string) []byte {
+	mac := hmac.New(sha256.New, m.hmacKey)
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
+}
+
+func (m *Manager) loadTokens() (tokens []storedToken) {
+	result, err := m.db.Get(tokensKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &tokens); err != nil {
+		return nil
+	}
+	return tokens
+}
+
+func (m *Manager) persistTokens(tokens []storedToken) error {
+	toWrite, err := rlp.EncodeToBytes(tokens)
+	if err != nil {
+		return err
+	}
+	return m.db.Put(tokensKey, toWrite)
+}
+
+// CreateToken generates and persists a new token with the given name and
+// scopes, returning the "name:secret" token string to hand to the client.
+// The secret itself is never stored, only the HMAC of it.
+func (m *Manager) CreateToken(name string, scopes []string) (string, error) {
+	tokens := m.loadTokens()
+	for _, t := range tokens {
+		if t.Name == name {
+			return "", fmt.Errorf("token %s already exists", name)
+		}
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	secretHex := hex.EncodeToString(secret)
+
+	tokens = append(tokens, storedToken{
+		Name:       name,
+		SecretHash: m.hash(secretHex),
+		Scopes:     scopes,
+		CreatedAt:  time.Now().Unix(),
+	})
+	if err := m.persistTokens(tokens); err != nil {
+		return "", err
+	}
+	return name + ":" + secretHex, nil
+}
+
+// CheckToken returns true if tok is a valid, non-revoked token which was
+// granted scope
+func (m *Manager) CheckToken(tok string, scope string) (bool, error) {
+	splitted := strings.SplitN(tok, ":", 2)
+	if len(splitted) != 2 {
+		return false, fmt.Errorf("malformed token")
+	}
+	name, secret := splitted[0], splitted[1]
+
+	for _, t := range m.loadTokens() {
+		if t.Name != name {
+			continue
+		}
+		if subtle.ConstantTimeCompare(m.hash(secret), t.SecretHash) != 1 {
+			return false, nil
+		}
+		for _, s := range t.Scopes {
+			if s == scope {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, nil
+}
+
+// DeleteToken revokes the token with the given name
+func (m *Manager) DeleteToken(name string) error {
+	tokens := m.loadTokens()
+	filtered := tokens[:0]
+	for _, t := range tokens {
+		if t.Name != name {
+			filtered = append(filtered, t)
+		}
+	}
+	return m.persistTokens(filtered)
+}
+
+// ListTokens returns the name, scopes and creation time of every
+// registered token, without leaking any secret material
+func (m *Manager) ListTokens() (infos []TokenInfo, err error) {
+	for _, t := range m.loadTokens() {
+		infos = append(infos, TokenInfo{Name: t.Name, Scopes: t.Scopes, CreatedAt: t.CreatedAt})
+	}
+	return
+}
@@ -0,0 +1,250 @@
+package alerter
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// Aggregator accumulates a running aggregate value over a sequence of
+// inputs added one at a time, letting a GROUP BY query fold an unbounded
+// stream of rows without ever buffering them.
+type Aggregator interface {
+	// Add folds value into the aggregator's running state
+	Add(value Value) error
+	// Result returns the aggregate's current value
+	Result() (Value, error)
+}
+
+// AggregateFunction is a factory for a named aggregate (COUNT, SUM, ...)
+// usable as a GROUP BY projection. New returns a fresh Aggregator seeded
+// with identity state for one group; a query with N groups holds N
+// independently running Aggregators, one per group, each created by its
+// own call to New.
+type AggregateFunction interface {
+	Name() string
+	New() Aggregator
+}
+
+var (
+	aggregatesMu sync.RWMutex
+	aggregates   = make(map[string]AggregateFunction)
+)
+
+// RegisterAggregate adds f to the global aggregate registry under its
+// lower cased Name(), mirroring RegisterBuiltin: it panics on a nil or
+// duplicate registration and is meant to be called from an init
+// function, before any query is executed.
+func RegisterAggregate(f AggregateFunction) {
+	if f == nil {
+		panic("alerter: RegisterAggregate called with nil AggregateFunction")
+	}
+	name := strings.ToLower(f.Name())
+
+	aggregatesMu.Lock()
+	defer aggregatesMu.Unlock()
+	if _, exists := aggregates[name]; exists {
+		panic(fmt.Sprintf("alerter: aggregate %q already registered", name))
+	}
+	aggregates[name] = f
+}
+
+// LookupAggregate returns the aggregate registered under name, if any.
+// name is matched case-insensitively.
+func LookupAggregate(name string) (AggregateFunction, bool) {
+	aggregatesMu.RLock()
+	defer aggregatesMu.RUnlock()
+	f, exists := aggregates[strings.ToLower(name)]
+	return f, exists
+}
+
+// lookupAggregate resolves name the same way FunctionCall.Execute
+// resolves scalar builtins: env.Aggregates, if set, overrides the global
+// registry for the duration of one query.
+func lookupAggregate(env *Env, name string) (AggregateFunction, bool) {
+	lowered := strings.ToLower(name)
+	if env != nil {
+		if f, exists := env.Aggregates[lowered]; exists {
+			return f, true
+		}
+	}
+	return LookupAggregate(lowered)
+}
+
+// genericAggregateFunction is an AggregateFunction built from a closure
+// returning a fresh Aggregator, the same data-not-type trick
+// genericCallable uses for scalar builtins.
+type genericAggregateFunction struct {
+	name string
+	new  func() Aggregator
+}
+
+// Name returns the aggregate's registered name
+func (f *genericAggregateFunction) Name() string {
+	return f.name
+}
+
+// New returns a fresh Aggregator for a single group
+func (f *genericAggregateFunction) New() Aggregator {
+	return f.new()
+}
+
+// countAggregator counts the number of values added, regardless of type
+type countAggregator struct {
+	count int64
+}
+
+func (a *countAggregator) Add(value Value) error {
+	a.count++
+	return nil
+}
+
+func (a *countAggregator) Result() (Value, error) {
+	return NewIntValue(big.NewInt(a.count)), nil
+}
+
+// sumAggregator sums int-valued inputs
+type sumAggregator struct {
+	sum *big.Int
+}
+
+func (a *sumAggregator) Add(value Value) error {
+	n, err := valueAsInt(value)
+	if err != nil {
+		return err
+	}
+	a.sum.Add(a.sum, n)
+	return nil
+}
+
+func (a *sumAggregator) Result() (Value, error) {
+	return NewIntValue(a.sum), nil
+}
+
+// avgAggregator tracks a running sum and count, dividing only once
+// Result is read so the average is never recomputed from scratch
+type avgAggregator struct {
+	sum   *big.Int
+	count int64
+}
+
+func (a *avgAggregator) Add(value Value) error {
+	n, err := valueAsInt(value)
+	if err != nil {
+		return err
+	}
+	a.sum.Add(a.sum, n)
+	a.count++
+	return nil
+}
+
+func (a *avgAggregator) Result() (Value, error) {
+	if a.count == 0 {
+		return nil, fmt.Errorf("AVG of an empty group")
+	}
+	return NewIntValue(big.NewInt(0).Div(a.sum, big.NewInt(a.count))), nil
+}
+
+// minAggregator tracks a running minimum; value is nil until the first
+// input is added
+type minAggregator struct {
+	value *big.Int
+}
+
+func (a *minAggregator) Add(value Value) error {
+	n, err := valueAsInt(value)
+	if err != nil {
+		return err
+	}
+	if a.value == nil || n.Cmp(a.value) < 0 {
+		a.value = n
+	}
+	return nil
+}
+
+func (a *minAggregator) Result() (Value, error) {
+	if a.value == nil {
+		return nil, fmt.Errorf("MIN of an empty group")
+	}
+	return NewIntValue(a.value), nil
+}
+
+// maxAggregator is the running-maximum equivalent of minAggregator
+type maxAggregator struct {
+	value *big.Int
+}
+
+func (a *maxAggregator) Add(value Value) error {
+	n, err := valueAsInt(value)
+	if err != nil {
+		return err
+	}
+	if a.value == nil || n.Cmp(a.value) > 0 {
+		a.value = n
+	}
+	return nil
+}
+
+func (a *maxAggregator) Result() (Value, error) {
+	if a.value == nil {
+		return nil, fmt.Errorf("MAX of an empty group")
+	}
+	return NewIntValue(a.value), nil
+}
+
+// distinctAggregator counts the number of distinct values added, keyed
+// by each value's serialized representation so equal ints/strings/bytes
+// collapse regardless of type
+type distinctAggregator struct {
+	seen map[string]struct{}
+}
+
+func (a *distinctAggregator) Add(value Value) error {
+	a.seen[serializeValue(value)] = struct{}{}
+	return nil
+}
+
+func (a *distinctAggregator) Result() (Value, error) {
+	return NewIntValue(big.NewInt(int64(len(a.seen)))), nil
+}
+
+// serializeValue returns a deterministic string representation of value,
+// used both to dedupe distinctAggregator's inputs and to key
+// GroupByExecutor's per-group state off a tuple of Values
+func serializeValue(value Value) string {
+	return fmt.Sprintf("%v", value.Raw())
+}
+
+// serializeValues joins each value's serializeValue, prefixed with its
+// length, with a separator, so a value whose representation contains the
+// separator cannot make tuples of different shapes collide
+func serializeValues(values []Value) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		serialized := serializeValue(value)
+		parts[i] = fmt.Sprintf("%d:%s", len(serialized), serialized)
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+func init() {
+	RegisterAggregate(&genericAggregateFunction{name: "count", new: func() Aggregator {
+		return &countAggregator{}
+	}})
+	RegisterAggregate(&genericAggregateFunction{name: "sum", new: func() Aggregator {
+		return &sumAggregator{sum: big.NewInt(0)}
+	}})
+	RegisterAggregate(&genericAggregateFunction{name: "avg", new: func() Aggregator {
+		return &avgAggregator{sum: big.NewInt(0)}
+	}})
+	RegisterAggregate(&genericAggregateFunction{name: "min", new: func() Aggregator {
+		return &minAggregator{}
+	}})
+	RegisterAggregate(&genericAggregateFunction{name: "max", new: func() Aggregator {
+		return &maxAggregator{}
+	}})
+	RegisterAggregate(&genericAggregateFunction{name: "distinct", new: func() Aggregator {
+		return &distinctAggregator{seen: make(map[string]struct{})}
+	}})
+}
@@ -0,0 +1,76 @@
+package alerter
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intVals(ns ...int64) []Value {
+	values := make([]Value, len(ns))
+	for i, n := range ns {
+		values[i] = NewIntValue(big.NewInt(n))
+	}
+	return values
+}
+
+func runAggregate(t *testing.T, name string, values []Value) Value {
+	aggregate, ok := LookupAggregate(name)
+	assert.True(t, ok)
+	aggregator := aggregate.New()
+	for _, value := range values {
+		assert.NoError(t, aggregator.Add(value))
+	}
+	result, err := aggregator.Result()
+	assert.NoError(t, err)
+	return result
+}
+
+func TestCountAggregator(t *testing.T) {
+	result := runAggregate(t, "COUNT", intVals(10, 20, 30))
+	assert.Equal(t, NewIntValue(big.NewInt(3)), result)
+}
+
+func TestSumAggregator(t *testing.T) {
+	result := runAggregate(t, "sum", intVals(1, 2, 3))
+	assert.Equal(t, NewIntValue(big.NewInt(6)), result)
+}
+
+func TestAvgAggregator(t *testing.T) {
+	result := runAggregate(t, "avg", intVals(2, 4, 9))
+	assert.Equal(t, NewIntValue(big.NewInt(5)), result)
+}
+
+func TestAvgAggregatorEmptyGroup(t *testing.T) {
+	aggregate, _ := LookupAggregate("avg")
+	_, err := aggregate.New().Result()
+	assert.Error(t, err)
+}
+
+func TestMinMaxAggregators(t *testing.T) {
+	assert.Equal(t, NewIntValue(big.NewInt(2)), runAggregate(t, "min", intVals(5, 2, 9)))
+	assert.Equal(t, NewIntValue(big.NewInt(9)), runAggregate(t, "max", intVals(5, 2, 9)))
+}
+
+func TestDistinctAggregator(t *testing.T) {
+	result := runAggregate(t, "distinct", intVals(1, 2, 2, 3, 1))
+	assert.Equal(t, NewIntValue(big.NewInt(3)), result)
+}
+
+func TestLookupAggregateUnknown(t *testing.T) {
+	_, ok := LookupAggregate("median")
+	assert.False(t, ok)
+}
+
+func TestRegisterAggregatePanicsOnDuplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterAggregate(&genericAggregateFunction{name: "count", new: func() Aggregator { return &countAggregator{} }})
+	})
+}
+
+func TestRegisterAggregatePanicsOnNil(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterAggregate(nil)
+	})
+}
@@ -1,9 +1,14 @@
 package alerter
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/ethdb"
 )
@@ -14,91 +19,576 @@ var (
 
 // EmailConfig holds the configuration necessary to send emails
 type EmailConfig struct {
-	FromEmail    string
-	FromName     string
-	SMTPHost     string
-	SMTPPort     int
-	SMTPUser     string
-	SMTPPassword string
+	FromEmail    string `yaml:"from_email"`
+	FromName     string `yaml:"from_name"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUser     string `yaml:"smtp_user"`
+	SMTPPassword string `yaml:"smtp_password"`
+	// Templates holds named HTML templates SMTP destinations can opt into
+	// via a "?template=name" endpoint suffix (see NewSMTPSender), keyed by
+	// name. Populated from disk at startup and mergeable at runtime via
+	// Alerter.RegisterEmailTemplate.
+	Templates map[string]EmailTemplateConfig `yaml:"templates"`
+}
+
+// NotificationsConfig tunes the HTTP-based notification backends (Slack,
+// Discord, Telegram, PagerDuty, generic webhooks): the per-request
+// timeout and retry behavior shared by all of them, plus per-destination
+// custom webhook templates/headers that don't fit in a destination's
+// colon-delimited endpoint string
+type NotificationsConfig struct {
+	Timeout     time.Duration            `yaml:"timeout"`
+	MaxAttempts int                      `yaml:"max_attempts"`
+	Webhooks    map[string]WebhookConfig `yaml:"webhooks"`
+	// Queues customizes a message-queue destination (NATS/Kafka/AMQP),
+	// keyed by its endpoint string the same way Webhooks is
+	Queues map[string]QueueConfig `yaml:"queues"`
+	// SendTimeout bounds how long SendAlertWithContext waits on a single
+	// destination before giving up on it, independent of ctx; see
+	// sendTimeout
+	SendTimeout time.Duration `yaml:"send_timeout"`
+	// MaxConcurrentSends bounds how many destinations SendAlertWithContext
+	// delivers to at once, so a burst of slow destinations can't pile up
+	// unbounded goroutines; see maxConcurrentSends
+	MaxConcurrentSends int `yaml:"max_concurrent_sends"`
+}
+
+// QueueConfig customizes a single message-queue destination, keyed by its
+// endpoint in NotificationsConfig.Queues
+type QueueConfig struct {
+	// KeyTemplate, when set, is parsed as a text/template and rendered
+	// against an AlertPayload to build the per-message partition key
+	// (Kafka message key / AMQP routing key) or NATS subject suffix,
+	// letting downstream consumers shard work by e.g. the matched
+	// contract address
+	KeyTemplate string `yaml:"key_template"`
+}
+
+// WebhookConfig customizes a single generic-webhook destination, keyed by
+// its endpoint URL in NotificationsConfig.Webhooks
+type WebhookConfig struct {
+	// Template, when set, is parsed as a text/template and rendered
+	// against an AlertPayload to build the request body, in place of the
+	// default JSON-encoded AlertPayload
+	Template string `yaml:"template"`
+	// Headers are set on every request sent to this destination
+	Headers map[string]string `yaml:"headers"`
 }
 
 // Config holds the necessary configuration to send the alerts
 type Config struct {
-	Email EmailConfig
+	Email         EmailConfig         `yaml:"email"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Syslog        SyslogConfig        `yaml:"syslog"`
+	Dedup         DedupConfig         `yaml:"dedup"`
+	Watch         WatchConfig         `yaml:"watch"`
 }
 
 // Alerter contains all the logic to register and send alerts
 type Alerter struct {
-	config       *Config
-	destinations map[string]Sender
-	db           ethdb.Database
+	config *Config
+	db     ethdb.Database
+
+	destinationsMu sync.RWMutex
+	destinations   map[string]Sender
+
+	queriesMu sync.RWMutex
+	queries   map[string]RegisteredQuery
+
+	viewsMu sync.RWMutex
+	views   map[string]RegisteredView
+
+	streamingMu      sync.RWMutex
+	streamingQueries map[string]*streamingQuery
+
+	// logs, headers and chain back RunLiveQuery's streaming subscriptions;
+	// nil until SetLiveSource is called by the node at startup
+	logs    LogSource
+	headers HeaderSource
+	chain   ChainState
+
+	// recentAlerts backs SendAlertWithContext's duplicate suppression (see
+	// AlertFingerprint); restored from db on startup so a restart doesn't
+	// unleash a duplicate flood of whatever was about to fall out of its
+	// dedup window.
+	recentAlerts *fingerprintCache
+
+	limitersMu          sync.Mutex
+	destinationLimiters map[string]*rateLimiter
+
+	silencesMu sync.Mutex
+	silences   map[string]int64
+
+	watchesMu sync.Mutex
+	watches   map[string]*WatchedPredicate
 }
 
-// NewAlerter creates a new Alerter
+// NewAlerter creates a new Alerter and reloads any destinations and
+// registered queries persisted by a previous run
 func NewAlerter(config *Config, db ethdb.Database) *Alerter {
-	return &Alerter{
-		config:       config,
-		destinations: make(map[string]Sender),
-		db:           db,
+	a := &Alerter{
+		config:              config,
+		destinations:        make(map[string]Sender),
+		queries:             make(map[string]RegisteredQuery),
+		views:               make(map[string]RegisteredView),
+		db:                  db,
+		streamingQueries:    make(map[string]*streamingQuery),
+		recentAlerts:        newFingerprintCache(dedupCacheSize),
+		destinationLimiters: make(map[string]*rateLimiter),
+		silences:            make(map[string]int64),
+		watches:             make(map[string]*WatchedPredicate),
 	}
+	a.reload()
+	return a
 }
 
-func (a *Alerter) loadDestinations() (destinations []string) {
-	result, err := a.db.Get(DestinationsKey)
-	if err != nil {
-		return
+// reload restores the destinations and registered queries persisted to db,
+// skipping entries that can no longer be registered (e.g. an obsolete
+// transport) rather than failing startup
+func (a *Alerter) reload() {
+	for _, destination := range a.loadDestinations() {
+		sender, err := a.newSender(destination)
+		if err != nil {
+			continue
+		}
+		a.destinationsMu.Lock()
+		a.destinations[destination.String()] = sender
+		a.destinationsMu.Unlock()
+	}
+	for _, query := range a.loadQueries() {
+		a.queriesMu.Lock()
+		a.queries[query.ID] = query
+		a.queriesMu.Unlock()
+	}
+	for _, view := range a.loadViews() {
+		a.viewsMu.Lock()
+		a.views[view.Name] = view
+		a.viewsMu.Unlock()
+	}
+	a.recentAlerts.restore(a.loadFingerprints())
+	now := time.Now().Unix()
+	for _, silence := range a.loadSilences() {
+		if int64(silence.Until) > now {
+			a.silences[silence.Fingerprint] = int64(silence.Until)
+		}
+	}
+	if a.config.Email.Templates == nil {
+		a.config.Email.Templates = make(map[string]EmailTemplateConfig)
+	}
+	for _, tmpl := range a.loadEmailTemplates() {
+		a.config.Email.Templates[tmpl.Name] = EmailTemplateConfig{HTML: tmpl.HTML}
 	}
-	json.Unmarshal(result, &destinations)
-	return
 }
 
-func (a *Alerter) persistDestination(destination string) error {
+// newSender instantiates the Sender for destination without touching
+// a.destinations, leaving the caller to decide how to guard the write
+// (reload doesn't need atomicity with a concurrent exists-check;
+// RegisterDestination does).
+func (a *Alerter) newSender(destination AlertDestination) (Sender, error) {
+	senderFactory, ok := senders[destination.Transport]
+	if !ok {
+		logger.Warn("skipping destination with unknown transport", "transport", destination.Transport)
+		return nil, fmt.Errorf("unknown transport type %s", destination.Transport)
+	}
+	logger.Info("registered alert destination", "backend", destination.Transport)
+	return senderFactory(destination.Endpoint, a.config), nil
+}
+
+func (a *Alerter) persistDestination(destination AlertDestination) error {
 	destinations := a.loadDestinations()
 	destinations = append(destinations, destination)
-	toWrite, err := json.Marshal(destinations)
-	if err != nil {
-		return err
-	}
-	return a.db.Put(DestinationsKey, toWrite)
+	return a.persistDestinations(destinations)
 }
 
 // RegisterDestination registers a new destination to which to send
 // the alert when one is triggered
 func (a *Alerter) RegisterDestination(destination string) (bool, error) {
-	if _, ok := a.destinations[destination]; ok {
+	splitted := strings.SplitN(destination, ":", 2)
+	if len(splitted) != 2 {
+		return false, fmt.Errorf("invalid destination %s", destination)
+	}
+	dest := AlertDestination{Transport: splitted[0], Endpoint: splitted[1]}
+	sender, err := a.newSender(dest)
+	if err != nil {
+		return false, err
+	}
+
+	a.destinationsMu.Lock()
+	if _, exists := a.destinations[destination]; exists {
+		a.destinationsMu.Unlock()
 		return false, nil
 	}
-	splitted := strings.SplitN(destination, ":", 2)
-	transport := splitted[0]
-	endpoint := splitted[1]
-	senderFactory, ok := senders[transport]
+	a.destinations[destination] = sender
+	a.destinationsMu.Unlock()
+
+	err = a.persistDestination(dest)
+	return true, err
+}
+
+// DeregisterDestination removes destination (in "transport:endpoint" form)
+// from both a.destinations and the persisted list, returning false if it
+// wasn't registered. The removed sender is closed first if it implements
+// Closer, the same as Close does for every destination at shutdown.
+func (a *Alerter) DeregisterDestination(destination string) (bool, error) {
+	a.destinationsMu.Lock()
+	sender, ok := a.destinations[destination]
+	if ok {
+		delete(a.destinations, destination)
+	}
+	a.destinationsMu.Unlock()
 	if !ok {
-		return false, fmt.Errorf("unknown transport type %s", transport)
+		return false, nil
 	}
-	a.destinations[destination] = senderFactory(endpoint, a.config)
-	err := a.persistDestination(destination)
-	return true, err
+	if closer, ok := sender.(Closer); ok {
+		closer.Close()
+	}
+
+	destinations := a.loadDestinations()
+	remaining := destinations[:0]
+	for _, dest := range destinations {
+		if dest.String() != destination {
+			remaining = append(remaining, dest)
+		}
+	}
+	return true, a.persistDestinations(remaining)
 }
 
 // ListDestinations returns the list of registered destination
 func (a *Alerter) ListDestinations() (destinations []string, err error) {
+	a.destinationsMu.RLock()
+	defer a.destinationsMu.RUnlock()
 	for destination := range a.destinations {
 		destinations = append(destinations, destination)
 	}
 	return
 }
 
-// SendAlert send alerts to all the registered destinations
-func (a *Alerter) SendAlert(subject string, message string) error {
-	var errors []string
+// Close releases resources (e.g. idle HTTP connections) held by every
+// registered destination which implements Closer
+func (a *Alerter) Close() {
+	a.destinationsMu.RLock()
+	defer a.destinationsMu.RUnlock()
 	for _, sender := range a.destinations {
-		err := sender.Send(subject, message)
+		if closer, ok := sender.(Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// RegisterQuery parses and persists a new EMQL SELECT statement, returning
+// the created RegisteredQuery
+func (a *Alerter) RegisterQuery(rawEMQL string, owner string) (*RegisteredQuery, error) {
+	parser, err := NewParser(NewLexer(rawEMQL))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parser.ParseSelect(); err != nil {
+		logger.Warn("rejected invalid EMQL query", "owner", owner, "err", err)
+		return nil, fmt.Errorf("invalid EMQL query: %v", err)
+	}
+
+	query := RegisteredQuery{
+		ID:        newQueryID(),
+		RawEMQL:   rawEMQL,
+		CreatedAt: uint64(time.Now().Unix()),
+		Owner:     owner,
+	}
+	a.queriesMu.Lock()
+	a.queries[query.ID] = query
+	a.queriesMu.Unlock()
+
+	queries := a.loadQueries()
+	queries = append(queries, query)
+	if err := a.persistQueries(queries); err != nil {
+		return nil, err
+	}
+	logger.Info("registered EMQL query", "query_id", query.ID, "owner", owner)
+	return &query, nil
+}
+
+// ListQueries returns all registered EMQL queries
+func (a *Alerter) ListQueries() (queries []RegisteredQuery, err error) {
+	a.queriesMu.RLock()
+	defer a.queriesMu.RUnlock()
+	for _, query := range a.queries {
+		queries = append(queries, query)
+	}
+	return
+}
+
+// RegisterView parses rawEMQL as a "CREATE VIEW name AS select ..."
+// statement and persists it so later queries can FROM it by name the same
+// way they reference a CTE, returning the created RegisteredView
+func (a *Alerter) RegisterView(rawEMQL string) (*RegisteredView, error) {
+	parser, err := NewParser(NewLexer(rawEMQL))
+	if err != nil {
+		return nil, err
+	}
+	statement, err := parser.ParseStatement()
+	if err != nil {
+		logger.Warn("rejected invalid EMQL view", "err", err)
+		return nil, fmt.Errorf("invalid EMQL view: %v", err)
+	}
+	createView, ok := statement.(*CreateViewStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected a CREATE VIEW statement, got a plain SELECT")
+	}
+	a.viewsMu.Lock()
+	if _, exists := a.views[createView.Name]; exists {
+		a.viewsMu.Unlock()
+		return nil, fmt.Errorf("view %s is already registered", createView.Name)
+	}
+
+	view := RegisteredView{
+		Name:      createView.Name,
+		RawEMQL:   rawEMQL,
+		CreatedAt: uint64(time.Now().Unix()),
+	}
+	a.views[view.Name] = view
+	a.viewsMu.Unlock()
+
+	views := a.loadViews()
+	views = append(views, view)
+	if err := a.persistViews(views); err != nil {
+		return nil, err
+	}
+	logger.Info("registered EMQL view", "name", view.Name)
+	return &view, nil
+}
+
+// ListViews returns all registered views
+func (a *Alerter) ListViews() (views []RegisteredView, err error) {
+	a.viewsMu.RLock()
+	defer a.viewsMu.RUnlock()
+	for _, view := range a.views {
+		views = append(views, view)
+	}
+	return
+}
+
+// viewStatements parses every registered view's raw EMQL (a full "CREATE
+// VIEW name AS select ..." statement, as RegisterView stores it) into its
+// underlying SelectStatement, for use as the views argument to
+// ResolveSources. A view that no longer parses (e.g. after a breaking
+// grammar change) is skipped rather than failing every query that
+// doesn't even reference it.
+func (a *Alerter) viewStatements() map[string]*SelectStatement {
+	a.viewsMu.RLock()
+	defer a.viewsMu.RUnlock()
+	statements := make(map[string]*SelectStatement, len(a.views))
+	for name, view := range a.views {
+		parser, err := NewParser(NewLexer(view.RawEMQL))
 		if err != nil {
-			errors = append(errors, err.Error())
+			continue
 		}
+		stmt, err := parser.ParseStatement()
+		if err != nil {
+			continue
+		}
+		createView, ok := stmt.(*CreateViewStatement)
+		if !ok {
+			continue
+		}
+		statements[name] = createView.Query
 	}
-	if len(errors) == 0 {
+	return statements
+}
+
+// newQueryID returns a random hex identifier for a RegisteredQuery
+func newQueryID() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// SendResult is the structured, per-destination outcome of a SendAlert or
+// SendAlertWithContext call
+type SendResult struct {
+	// Errors maps a destination (in "transport:endpoint" form) to the
+	// error encountered delivering to it; a destination that delivered
+	// successfully is absent.
+	Errors map[string]error
+}
+
+// Err returns a single combined error listing every failed destination in
+// r, or nil if every destination succeeded, for callers that only care
+// whether the send as a whole succeeded
+func (r *SendResult) Err() error {
+	if len(r.Errors) == 0 {
 		return nil
 	}
-	return fmt.Errorf("some destination failed: %s", strings.Join(errors, "; "))
+	destinations := make([]string, 0, len(r.Errors))
+	for destination := range r.Errors {
+		destinations = append(destinations, destination)
+	}
+	sort.Strings(destinations)
+	messages := make([]string, len(destinations))
+	for i, destination := range destinations {
+		messages[i] = fmt.Sprintf("%s: %v", destination, r.Errors[destination])
+	}
+	return fmt.Errorf("some destinations failed: %s", strings.Join(messages, "; "))
+}
+
+// SendAlert send alerts to all the registered destinations
+func (a *Alerter) SendAlert(ctx context.Context, subject string, message string) (*SendResult, error) {
+	return a.SendAlertWithContext(ctx, subject, message, AlertContext{AlertID: newQueryID()})
+}
+
+// SendAlertWithContext behaves like SendAlert, but additionally attaches
+// alertCtx (query id, matched block, projected columns) to the payload
+// delivered to destinations that support structured delivery.
+//
+// Destinations are delivered to concurrently, bounded by
+// NotificationsConfig.MaxConcurrentSends workers, so one slow destination
+// (e.g. an unresponsive SMTP server) cannot delay delivery to the others.
+// Each destination additionally gets its own
+// NotificationsConfig.SendTimeout deadline layered on top of ctx; per-
+// destination retry on transient errors is handled inside each Sender
+// (see withRetry).
+func (a *Alerter) SendAlertWithContext(ctx context.Context, subject string, message string, alertCtx AlertContext) (*SendResult, error) {
+	if alertCtx.AlertID == "" {
+		alertCtx.AlertID = newQueryID()
+	}
+
+	fingerprint := AlertFingerprint(subject, message)
+	if a.silenced(fingerprint) {
+		logger.Info("dropping silenced alert", "fingerprint", fingerprint)
+		return &SendResult{}, nil
+	}
+	if !a.recentAlerts.allow(fingerprint, time.Now().Unix(), a.config.Dedup.window()) {
+		logger.Info("suppressing duplicate alert", "fingerprint", fingerprint)
+		return &SendResult{}, nil
+	}
+	if err := a.persistFingerprints(a.recentAlerts.snapshot()); err != nil {
+		logger.Warn("failed to persist dedup state", "err", err)
+	}
+
+	type outcome struct {
+		destination string
+		err         error
+	}
+
+	a.destinationsMu.RLock()
+	outcomes := make(chan outcome, len(a.destinations))
+	sem := make(chan struct{}, a.config.Notifications.maxConcurrentSends())
+	var wg sync.WaitGroup
+	for destination, sender := range a.destinations {
+		destination, sender := destination, sender
+		if !a.limiterFor(destination).allow(time.Now()) {
+			outcomes <- outcome{destination, fmt.Errorf("rate limit exceeded (max %d/min)", a.config.Dedup.maxPerMinute())}
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := a.sendToDestination(ctx, destination, sender, subject, message, alertCtx)
+			outcomes <- outcome{destination, err}
+		}()
+	}
+	a.destinationsMu.RUnlock()
+	wg.Wait()
+	close(outcomes)
+
+	result := &SendResult{Errors: make(map[string]error)}
+	for o := range outcomes {
+		if o.err != nil {
+			result.Errors[o.destination] = o.err
+		}
+	}
+	return result, nil
+}
+
+// sendToDestination delivers subject/message/alertCtx to a single
+// destination, bounding it with its own NotificationsConfig.SendTimeout
+// deadline, and records the alerts_sent_total/alerts_send_duration_seconds
+// metrics and the "alert dispatched" log line for it
+func (a *Alerter) sendToDestination(ctx context.Context, destination string, sender Sender, subject string, message string, alertCtx AlertContext) error {
+	sendCtx, cancel := context.WithTimeout(ctx, a.config.Notifications.sendTimeout())
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	if structured, ok := sender.(StructuredSender); ok {
+		err = structured.SendStructured(sendCtx, subject, message, alertCtx)
+	} else {
+		err = sender.Send(sendCtx, subject, message)
+	}
+	duration := time.Since(start)
+
+	transport := transportOf(destination)
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	alertsSentCounter(transport, status).Inc(1)
+	alertsSendDurationTimer(transport).Update(duration)
+
+	logger.Info("alert dispatched",
+		"query_id", alertCtx.QueryID,
+		"alert_id", alertCtx.AlertID,
+		"matched_at_block", alertCtx.MatchedAtBlock,
+		"backend", transport,
+		"latency", duration,
+		"status", status,
+		"err", err,
+	)
+	return err
+}
+
+// transportOf returns the transport prefix of a destination's
+// "transport:endpoint" string, for use as a log field
+func transportOf(destination string) string {
+	return strings.SplitN(destination, ":", 2)[0]
+}
+
+// limiterFor returns destination's rateLimiter, creating one bounded by
+// Config.Dedup.MaxPerMinute on first use
+func (a *Alerter) limiterFor(destination string) *rateLimiter {
+	a.limitersMu.Lock()
+	defer a.limitersMu.Unlock()
+	limiter, ok := a.destinationLimiters[destination]
+	if !ok {
+		limiter = &rateLimiter{max: a.config.Dedup.maxPerMinute()}
+		a.destinationLimiters[destination] = limiter
+	}
+	return limiter
+}
+
+// silenced reports whether fingerprint is currently muted by SilenceAlert
+func (a *Alerter) silenced(fingerprint string) bool {
+	a.silencesMu.Lock()
+	defer a.silencesMu.Unlock()
+	until, ok := a.silences[fingerprint]
+	return ok && until > time.Now().Unix()
+}
+
+// SilenceAlert mutes fingerprint (see AlertFingerprint) for duration, so an
+// operator can quiet one known-noisy (subject, message) predicate without
+// deregistering the destinations it fires against
+func (a *Alerter) SilenceAlert(fingerprint string, duration time.Duration) error {
+	until := time.Now().Add(duration).Unix()
+
+	a.silencesMu.Lock()
+	a.silences[fingerprint] = until
+	a.silencesMu.Unlock()
+
+	silences := a.loadSilences()
+	silences = append(silences, Silence{Fingerprint: fingerprint, Until: uint64(until)})
+	return a.persistSilences(silences)
+}
+
+// ListSilences returns every currently-active silence
+func (a *Alerter) ListSilences() (silences []Silence, err error) {
+	a.silencesMu.Lock()
+	defer a.silencesMu.Unlock()
+	now := time.Now().Unix()
+	for fingerprint, until := range a.silences {
+		if until > now {
+			silences = append(silences, Silence{Fingerprint: fingerprint, Until: uint64(until)})
+		}
+	}
+	return
 }
@@ -1,19 +1,40 @@
 package alerter
 
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/alerter/accesstoken"
+	"github.com/ethereum/go-ethereum/rpc"
+)
 
 // PublicAlerterAPI exposes the functionality of Alerter to the RPC client
 type PublicAlerterAPI struct {
 	alerter *Alerter
+	tokens  *accesstoken.Manager
 }
 
 
 // NewPublicAlerterAPI create a new PublicAlerterAPI.
-func NewPublicAlerterAPI(alerter *Alerter) *PublicAlerterAPI {
+func NewPublicAlerterAPI(alerter *Alerter, tokens *accesstoken.Manager) *PublicAlerterAPI {
 	return &PublicAlerterAPI{
 		alerter: alerter,
+		tokens:  tokens,
 	}
 }
 
+// requireScope returns an error unless token grants scope
+func (api *PublicAlerterAPI) requireScope(token string, scope string) error {
+	ok, err := api.tokens.CheckToken(token, scope)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("token does not grant the %s scope", scope)
+	}
+	return nil
+}
 
 // RegisterDestination delegates to Alerter.RegisterDestination
 // Destination should have the following format:
@@ -24,16 +45,172 @@ func NewPublicAlerterAPI(alerter *Alerter) *PublicAlerterAPI {
 // smtp:alert@example.com
 // SMTP configuration must be set through command line options for
 // the STMP transport to work
-func (api *PublicAlerterAPI) RegisterDestination(destination string) (bool, error) {
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) RegisterDestination(token string, destination string) (bool, error) {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return false, err
+	}
 	return api.alerter.RegisterDestination(destination)
 }
 
+// DeregisterDestination delegates to Alerter.DeregisterDestination
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) DeregisterDestination(token string, destination string) (bool, error) {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return false, err
+	}
+	return api.alerter.DeregisterDestination(destination)
+}
+
 // ListDestinations delegates to Alerter.ListDestinations
-func (api *PublicAlerterAPI) ListDestinations() ([]string, error) {
+// Requires a token with the alerter:read scope.
+func (api *PublicAlerterAPI) ListDestinations(token string) ([]string, error) {
+	if err := api.requireScope(token, "alerter:read"); err != nil {
+		return nil, err
+	}
 	return api.alerter.ListDestinations()
 }
 
-// SendTestAlert delegates to Alerter.SendAlert
-func (api *PublicAlerterAPI) SendTestAlert(subject string, message string) error {
-	return api.alerter.SendAlert(subject, message)
+// SendTestAlert delegates to Alerter.SendAlert. ctx is supplied by the RPC
+// server and canceled if the client disconnects before delivery finishes.
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) SendTestAlert(ctx context.Context, token string, subject string, message string) error {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return err
+	}
+	result, err := api.alerter.SendAlert(ctx, subject, message)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// SilenceAlert delegates to Alerter.SilenceAlert, muting the given
+// AlertFingerprint for durationSeconds.
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) SilenceAlert(token string, fingerprint string, durationSeconds int64) error {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return err
+	}
+	return api.alerter.SilenceAlert(fingerprint, time.Duration(durationSeconds)*time.Second)
+}
+
+// ListSilences delegates to Alerter.ListSilences
+// Requires a token with the alerter:read scope.
+func (api *PublicAlerterAPI) ListSilences(token string) ([]Silence, error) {
+	if err := api.requireScope(token, "alerter:read"); err != nil {
+		return nil, err
+	}
+	return api.alerter.ListSilences()
+}
+
+// RegisterEmailTemplate delegates to Alerter.RegisterEmailTemplate
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) RegisterEmailTemplate(token string, name string, html string) (*EmailTemplate, error) {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return nil, err
+	}
+	return api.alerter.RegisterEmailTemplate(name, html)
+}
+
+// ListEmailTemplates delegates to Alerter.ListEmailTemplates
+// Requires a token with the alerter:read scope.
+func (api *PublicAlerterAPI) ListEmailTemplates(token string) ([]EmailTemplate, error) {
+	if err := api.requireScope(token, "alerter:read"); err != nil {
+		return nil, err
+	}
+	return api.alerter.ListEmailTemplates()
+}
+
+// RenderPreview delegates to Alerter.RenderPreview, letting an operator
+// dry-run an email template without firing a real alert.
+// Requires a token with the alerter:read scope.
+func (api *PublicAlerterAPI) RenderPreview(token string, subject string, message string, template string) (*EmailPreview, error) {
+	if err := api.requireScope(token, "alerter:read"); err != nil {
+		return nil, err
+	}
+	return api.alerter.RenderPreview(subject, message, template)
+}
+
+// SetLogLevel hot-reloads the alerter pipeline's structured log level
+// (trace/debug/info/warn/error/crit) without restarting the node, e.g. to
+// turn on debug tracing of EMQL evaluation against a live node.
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) SetLogLevel(token string, level string) error {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return err
+	}
+	return SetLogLevel(level)
+}
+
+// RegisterQuery delegates to Alerter.RegisterQuery
+// Requires a token with the alerter:write scope.
+func (api *PublicAlerterAPI) RegisterQuery(token string, rawEMQL string, owner string) (*RegisteredQuery, error) {
+	if err := api.requireScope(token, "alerter:write"); err != nil {
+		return nil, err
+	}
+	return api.alerter.RegisterQuery(rawEMQL, owner)
+}
+
+// ListQueries delegates to Alerter.ListQueries
+// Requires a token with the alerter:read scope.
+func (api *PublicAlerterAPI) ListQueries(token string) ([]RegisteredQuery, error) {
+	if err := api.requireScope(token, "alerter:read"); err != nil {
+		return nil, err
+	}
+	return api.alerter.ListQueries()
+}
+
+// Subscribe parses query, installs its FROM clause as a log filter on the
+// alerter's live source (resolving SINCE/UNTIL into a one-time historical
+// backfill first) and streams matching rows to the client over a
+// notifier-backed subscription, following the same pattern as
+// eth_subscribe. LIMIT auto-unsubscribes the client once enough rows have
+// been delivered. Requires a token with the alerter:read scope.
+func (api *PublicAlerterAPI) Subscribe(ctx context.Context, token string, query string) (*rpc.Subscription, error) {
+	if err := api.requireScope(token, "alerter:read"); err != nil {
+		return nil, err
+	}
+
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	parser, err := NewParser(NewLexer(query))
+	if err != nil {
+		return nil, err
+	}
+	statement, err := parser.ParseSelect()
+	if err != nil {
+		return nil, err
+	}
+
+	liveCtx, cancel := context.WithCancel(ctx)
+	sq, err := api.alerter.RunLiveQuery(liveCtx, statement)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case row, ok := <-sq.rows:
+				if !ok {
+					return
+				}
+				notifier.Notify(rpcSub.ID, row)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
 }
@@ -1,12 +1,85 @@
 package alerter
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"math/big"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ValueType identifies the static result type of an expression or
+// operator. Resolving it once, at parse time, lets BinaryApplication and
+// UnaryApplication dispatch straight to a typed evaluator (EvalInt/
+// EvalBool/EvalString) instead of boxing every intermediate result into a
+// Value and re-checking IsInt/IsBool on each step.
+type ValueType int
+
+const (
+	// IntValueType marks an expression which evaluates to an int
+	IntValueType ValueType = iota
+	// BoolValueType marks an expression which evaluates to a bool
+	BoolValueType
+	// StringValueType marks an expression which evaluates to a string
+	StringValueType
+	// BytesValueType marks an expression which evaluates to raw bytes,
+	// e.g. the result of keccak256() or a parsed address
+	BytesValueType
 )
 
+func (t ValueType) String() string {
+	switch t {
+	case IntValueType:
+		return "int"
+	case BoolValueType:
+		return "bool"
+	case StringValueType:
+		return "string"
+	case BytesValueType:
+		return "bytes"
+	default:
+		return "unknown"
+	}
+}
+
+// valueAsInt converts a dynamically-typed Value to *big.Int, returning an
+// error rather than panicking when the value's static type could not be
+// resolved ahead of time (currently attributes and function calls)
+func valueAsInt(value Value) (*big.Int, error) {
+	if !value.IsInt() {
+		return nil, fmt.Errorf("expected int but got %v", value)
+	}
+	return value.ToInt(), nil
+}
+
+// valueAsBool is the bool equivalent of valueAsInt
+func valueAsBool(value Value) (bool, error) {
+	if !value.IsBool() {
+		return false, fmt.Errorf("expected bool but got %v", value)
+	}
+	return value.ToBool(), nil
+}
+
+// valueAsString is the string equivalent of valueAsInt
+func valueAsString(value Value) (string, error) {
+	if !value.IsString() {
+		return "", fmt.Errorf("expected string but got %v", value)
+	}
+	return value.ToString(), nil
+}
+
+// valueAsBytes is the []byte equivalent of valueAsInt
+func valueAsBytes(value Value) ([]byte, error) {
+	if !value.IsBytes() {
+		return nil, fmt.Errorf("expected bytes but got %v", value)
+	}
+	return value.ToBytes(), nil
+}
+
 // BinaryFunction is a generic (and very type unsafe) binary function
 type BinaryFunction func(Value, Value) Value
 
@@ -21,13 +94,29 @@ type BoolBinaryFunction func(bool, bool) bool
 
 // arithmetic binary operators
 var arithBinaryOperators = map[string]IntBinaryFunction{
-	"+": func(a, b *big.Int) *big.Int { return big.NewInt(0).Add(a, b) },
-	"-": func(a, b *big.Int) *big.Int { return big.NewInt(0).Sub(a, b) },
-	"*": func(a, b *big.Int) *big.Int { return big.NewInt(0).Mul(a, b) },
-	"/": func(a, b *big.Int) *big.Int { return big.NewInt(0).Div(a, b) },
-	"%": func(a, b *big.Int) *big.Int { return big.NewInt(0).Mod(a, b) },
+	"+":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Add(a, b) },
+	"-":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Sub(a, b) },
+	"*":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Mul(a, b) },
+	"/":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Div(a, b) },
+	"%":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Mod(a, b) },
+	"&":  func(a, b *big.Int) *big.Int { return big.NewInt(0).And(a, b) },
+	"|":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Or(a, b) },
+	"^":  func(a, b *big.Int) *big.Int { return big.NewInt(0).Xor(a, b) },
+	"<<": func(a, b *big.Int) *big.Int { return big.NewInt(0).Lsh(a, uint(b.Uint64())) },
+	">>": func(a, b *big.Int) *big.Int { return big.NewInt(0).Rsh(a, uint(b.Uint64())) },
 }
 
+// shiftOperators are the operators whose right operand is a shift amount
+// rather than a value, and so needs its own range validation
+var shiftOperators = map[string]bool{
+	"<<": true,
+	">>": true,
+}
+
+// maxShiftAmount bounds shift amounts so a query cannot force an
+// arbitrarily large allocation via e.g. "1 << 1000000000"
+const maxShiftAmount = 1024
+
 // comparison binary operators
 var comparisonBinaryOperators = map[string]CompBinaryFunction{
 	">":  func(a, b *big.Int) bool { return a.Cmp(b) > 0 },
@@ -57,6 +146,8 @@ var intUnaryOperators = map[string]IntUnaryFunction{
 	// arithmetic operators
 	"+": func(a *big.Int) *big.Int { return a },
 	"-": func(a *big.Int) *big.Int { return big.NewInt(0).Neg(a) },
+	// bitwise operators
+	"~": func(a *big.Int) *big.Int { return big.NewInt(0).Not(a) },
 }
 
 // comparison binary operators
@@ -101,12 +192,28 @@ func wrapBoolUnary(unaryFunc BoolUnaryFunction) UnaryFunction {
 type BinaryOperator interface {
 	Apply(left Value, right Value) (Value, error)
 	Equals(other interface{}) bool
+	// OperandType is the static type both operands must evaluate to
+	OperandType() ValueType
+	// ResultType is the static type Apply produces
+	ResultType() ValueType
 }
 
 // GenericBinaryOperator is a generic operator which takes two ints as operands
 type GenericBinaryOperator struct {
-	Name     string
-	Operator BinaryFunction
+	Name        string
+	Operator    BinaryFunction
+	operandType ValueType
+	resultType  ValueType
+}
+
+// OperandType returns the static type both operands must evaluate to
+func (a *GenericBinaryOperator) OperandType() ValueType {
+	return a.operandType
+}
+
+// ResultType returns the static type Apply produces
+func (a *GenericBinaryOperator) ResultType() ValueType {
+	return a.resultType
 }
 
 // NewIntBinOperator returns a binary operator which operates on ints from a string
@@ -114,12 +221,22 @@ type GenericBinaryOperator struct {
 func NewIntBinOperator(rawOperator string) (BinaryOperator, error) {
 	arithOperator, exists := arithBinaryOperators[rawOperator]
 	if exists {
-		return &GenericBinaryOperator{Name: rawOperator, Operator: wrapIntBinary(arithOperator)}, nil
+		return &GenericBinaryOperator{
+			Name:        rawOperator,
+			Operator:    wrapIntBinary(arithOperator),
+			operandType: IntValueType,
+			resultType:  IntValueType,
+		}, nil
 	}
 
 	compOperator, exists := comparisonBinaryOperators[rawOperator]
 	if exists {
-		return &GenericBinaryOperator{Name: rawOperator, Operator: wrapCompBinary(compOperator)}, nil
+		return &GenericBinaryOperator{
+			Name:        rawOperator,
+			Operator:    wrapCompBinary(compOperator),
+			operandType: IntValueType,
+			resultType:  BoolValueType,
+		}, nil
 	}
 	return nil, fmt.Errorf("expected a binary operator on ints, got: %s", rawOperator)
 }
@@ -135,7 +252,12 @@ func IsComparisonOperator(rawOperator string) bool {
 func NewCompOperator(rawOperator string) (BinaryOperator, error) {
 	compOperator, exists := comparisonBinaryOperators[rawOperator]
 	if exists {
-		return &GenericBinaryOperator{Name: rawOperator, Operator: wrapCompBinary(compOperator)}, nil
+		return &GenericBinaryOperator{
+			Name:        rawOperator,
+			Operator:    wrapCompBinary(compOperator),
+			operandType: IntValueType,
+			resultType:  BoolValueType,
+		}, nil
 	}
 	return nil, fmt.Errorf("expected a comparison operator, got: %s", rawOperator)
 }
@@ -144,7 +266,12 @@ func NewCompOperator(rawOperator string) (BinaryOperator, error) {
 func NewBoolBinOperator(rawOperator string) (BinaryOperator, error) {
 	compOperator, exists := boolBinaryOperators[rawOperator]
 	if exists {
-		return &GenericBinaryOperator{Name: rawOperator, Operator: wrapBoolBinary(compOperator)}, nil
+		return &GenericBinaryOperator{
+			Name:        rawOperator,
+			Operator:    wrapBoolBinary(compOperator),
+			operandType: BoolValueType,
+			resultType:  BoolValueType,
+		}, nil
 	}
 	return nil, fmt.Errorf("expected a boolean operator, got: %s", rawOperator)
 }
@@ -181,6 +308,16 @@ func (a *GenericBinaryOperator) Apply(left Value, right Value) (Value, error) {
 		return nil, fmt.Errorf("cannot cast %v to int", right)
 	}
 
+	if shiftOperators[a.Name] {
+		shiftAmount := right.ToInt()
+		if shiftAmount.Sign() < 0 {
+			return nil, fmt.Errorf("shift amount cannot be negative: %s", shiftAmount)
+		}
+		if !shiftAmount.IsUint64() || shiftAmount.Uint64() > maxShiftAmount {
+			return nil, fmt.Errorf("shift amount too large: %s", shiftAmount)
+		}
+	}
+
 	return a.Operator(left, right), nil
 }
 
@@ -190,12 +327,28 @@ func (a *GenericBinaryOperator) Apply(left Value, right Value) (Value, error) {
 type UnaryOperator interface {
 	Apply(operand Value) (Value, error)
 	Equals(other interface{}) bool
+	// OperandType is the static type the operand must evaluate to
+	OperandType() ValueType
+	// ResultType is the static type Apply produces
+	ResultType() ValueType
 }
 
 // IntUnaryOperator is an operator which takes an int as operands
 type IntUnaryOperator struct {
-	Operator UnaryFunction
-	Name     string
+	Operator    UnaryFunction
+	Name        string
+	operandType ValueType
+	resultType  ValueType
+}
+
+// OperandType returns the static type the operand must evaluate to
+func (i *IntUnaryOperator) OperandType() ValueType {
+	return i.operandType
+}
+
+// ResultType returns the static type Apply produces
+func (i *IntUnaryOperator) ResultType() ValueType {
+	return i.resultType
 }
 
 // Equals returns true if the operator equals other
@@ -225,7 +378,12 @@ func NewIntUnaryOperator(rawOperator string) (UnaryOperator, error) {
 	if !exists {
 		return nil, fmt.Errorf("operator %s not found", rawOperator)
 	}
-	return &IntUnaryOperator{Name: rawOperator, Operator: wrapIntUnary(operator)}, nil
+	return &IntUnaryOperator{
+		Name:        rawOperator,
+		Operator:    wrapIntUnary(operator),
+		operandType: IntValueType,
+		resultType:  IntValueType,
+	}, nil
 }
 
 // MustNewIntUnaryOperator returns a unary operator from a string
@@ -244,13 +402,31 @@ func NewBoolUnaryOperator(rawOperator string) (UnaryOperator, error) {
 	if !exists {
 		return nil, fmt.Errorf("operator %s not found", rawOperator)
 	}
-	return &IntUnaryOperator{Name: rawOperator, Operator: wrapBoolUnary(operator)}, nil
+	return &IntUnaryOperator{
+		Name:        rawOperator,
+		Operator:    wrapBoolUnary(operator),
+		operandType: BoolValueType,
+		resultType:  BoolValueType,
+	}, nil
 }
 
 // Expression is an arbitrary expression which returns a value when executed
 type Expression interface {
-	Execute(env *Env) (Value, error)
+	Execute(ctx context.Context, env *Env) (Value, error)
+	// EvalInt, EvalBool and EvalString are typed evaluators resolved once
+	// the node's static result type is known (at construction time for
+	// operator applications and literals). Calling the one matching the
+	// node's actual type skips boxing through Value entirely; calling a
+	// mismatched one returns an error instead of the panic ToInt/ToBool/
+	// ToString raise on a Value of the wrong type.
+	EvalInt(ctx context.Context, env *Env) (*big.Int, error)
+	EvalBool(ctx context.Context, env *Env) (bool, error)
+	EvalString(ctx context.Context, env *Env) (string, error)
 	Equals(other interface{}) bool
+	// String renders the expression back to its EMQL-ish form, used both
+	// for diagnostics (e.g. WatchedPredicate's default alert message) and
+	// as the projected row's column key in SelectStatement.EvaluateContext
+	String() string
 }
 
 // Attribute is an attribute such as tx.origin or msg.value
@@ -285,10 +461,97 @@ func (a *Attribute) Equals(rawOther interface{}) bool {
 	return true
 }
 
-// Execute retrieves the value of the attribute in the environment
-func (a *Attribute) Execute(env *Env) (Value, error) {
-	// TODO: implement
-	return nil, nil
+// Execute retrieves the value of the attribute in the environment: from
+// env.Row if a streaming query has already extracted it, otherwise by
+// resolving it against env.Resolver's namespace providers (tx, block,
+// log, contract, ...).
+func (a *Attribute) Execute(ctx context.Context, env *Env) (Value, error) {
+	if value, exists := env.Row[a.String()]; exists {
+		return value, nil
+	}
+	if env.Resolver == nil {
+		return nil, fmt.Errorf("cannot resolve attribute %s: no row or attribute resolver in scope", a)
+	}
+	return env.Resolver.Resolve(ctx, a.Parts)
+}
+
+// EvalInt evaluates the attribute and converts the result to an int.
+// The attribute's value comes from env.Row or env.Resolver at evaluation
+// time, so this goes through Execute and Value
+func (a *Attribute) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	value, err := a.Execute(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	return valueAsInt(value)
+}
+
+// EvalBool is the bool equivalent of EvalInt
+func (a *Attribute) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	value, err := a.Execute(ctx, env)
+	if err != nil {
+		return false, err
+	}
+	return valueAsBool(value)
+}
+
+// EvalString is the string equivalent of EvalInt
+func (a *Attribute) EvalString(ctx context.Context, env *Env) (string, error) {
+	value, err := a.Execute(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	return valueAsString(value)
+}
+
+// BindParamExpr is an unresolved "?name" bind parameter appearing in
+// arbitrary expression position, e.g. "msg.value > ?threshold". Unlike
+// FromSource.Param/BlockRef.Param/SelectStatement.LimitParam, which are
+// fixed string fields resolved in place by PreparedStatement.Bind,
+// BindParamExpr is a regular Expression node so it can appear anywhere an
+// expression can: Bind walks the tree (see substituteExpression) and
+// replaces every BindParamExpr with the literal Value bound to its name
+// before the statement is ever executed. Evaluating one directly is
+// always an error; a PreparedStatement that still contains one has a bug
+// in its own substitution, not a user-facing one.
+type BindParamExpr struct {
+	Name string
+}
+
+// NewBindParamExpr returns an unresolved bind parameter named name
+func NewBindParamExpr(name string) *BindParamExpr {
+	return &BindParamExpr{Name: name}
+}
+
+func (b *BindParamExpr) String() string {
+	return "?" + b.Name
+}
+
+// Equals returns true if the value equals other
+func (b *BindParamExpr) Equals(rawOther interface{}) bool {
+	other, ok := rawOther.(*BindParamExpr)
+	return ok && b.Name == other.Name
+}
+
+// Execute always fails: Bind must substitute this node before it is ever
+// executed
+func (b *BindParamExpr) Execute(ctx context.Context, env *Env) (Value, error) {
+	return nil, fmt.Errorf("unresolved bind parameter ?%s", b.Name)
+}
+
+// EvalInt always fails, for the same reason as Execute
+func (b *BindParamExpr) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("unresolved bind parameter ?%s", b.Name)
+}
+
+// EvalBool always fails, for the same reason as Execute
+func (b *BindParamExpr) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return false, fmt.Errorf("unresolved bind parameter ?%s", b.Name)
+}
+
+// EvalString always fails, for the same reason as Execute
+func (b *BindParamExpr) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("unresolved bind parameter ?%s", b.Name)
 }
 
 // BinaryApplication is a binary application of two expressions
@@ -301,17 +564,23 @@ type BinaryApplication struct {
 
 // NewIntBinaryApplication returns a new binary application which takes
 // from the left and right, which should both evaluate to ints
-// and the operands raw symbol for the operator
+// and the operands raw symbol for the operator. If left and right are
+// both literals, the application is folded to its result immediately
+// instead of being rebuilt on every evaluation.
 func NewIntBinaryApplication(left, right Expression, rawOperator string) (Expression, error) {
 	operator, err := NewIntBinOperator(rawOperator)
 	if err != nil {
 		return nil, err
 	}
-	return &BinaryApplication{
+	app := &BinaryApplication{
 		Left:     left,
 		Right:    right,
 		Operator: operator,
-	}, nil
+	}
+	if isConstantExpr(left) && isConstantExpr(right) {
+		return foldExpression(app), nil
+	}
+	return app, nil
 }
 
 // MustNewIntBinaryApplication does the same thing as NewBinaryApplication
@@ -336,38 +605,102 @@ func (a *BinaryApplication) Equals(rawOther interface{}) bool {
 	return false
 }
 
-// Execute evaluates left and right operands and finally applies the operator
-func (a *BinaryApplication) Execute(env *Env) (Value, error) {
-	left, err := a.Left.Execute(env)
+// evalOperand evaluates operand through the typed evaluator matching
+// operandType, boxing the result into a Value only at the very end so it
+// can be handed to BinaryOperator.Apply
+func evalOperand(ctx context.Context, env *Env, operand Expression, operandType ValueType) (Value, error) {
+	switch operandType {
+	case IntValueType:
+		value, err := operand.EvalInt(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+		return NewIntValue(value), nil
+	case BoolValueType:
+		value, err := operand.EvalBool(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+		return NewBoolValue(value), nil
+	default:
+		return nil, fmt.Errorf("unsupported operand type %v", operandType)
+	}
+}
+
+// apply evaluates left and right through the operator's static operand
+// type and applies the operator
+func (a *BinaryApplication) apply(ctx context.Context, env *Env) (Value, error) {
+	operandType := a.Operator.OperandType()
+	left, err := evalOperand(ctx, env, a.Left, operandType)
 	if err != nil {
 		return nil, err
 	}
-
-	right, err := a.Right.Execute(env)
+	right, err := evalOperand(ctx, env, a.Right, operandType)
 	if err != nil {
 		return nil, err
 	}
 	return a.Operator.Apply(left, right)
 }
 
+// Execute evaluates left and right operands and finally applies the operator
+func (a *BinaryApplication) Execute(ctx context.Context, env *Env) (Value, error) {
+	return a.apply(ctx, env)
+}
+
+// EvalInt evaluates the application as an int; valid only when the
+// operator's static result type is int (arithmetic operators)
+func (a *BinaryApplication) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	if a.Operator.ResultType() != IntValueType {
+		return nil, fmt.Errorf("%v does not evaluate to an int", a)
+	}
+	result, err := a.apply(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	return result.ToInt(), nil
+}
+
+// EvalBool evaluates the application as a bool; valid only when the
+// operator's static result type is bool (comparison and and/or operators)
+func (a *BinaryApplication) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	if a.Operator.ResultType() != BoolValueType {
+		return false, fmt.Errorf("%v does not evaluate to a bool", a)
+	}
+	result, err := a.apply(ctx, env)
+	if err != nil {
+		return false, err
+	}
+	return result.ToBool(), nil
+}
+
+// EvalString always fails: no binary operator produces a string
+func (a *BinaryApplication) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("%v does not evaluate to a string", a)
+}
+
 // PredBinaryApplication is a binary application which evaluates to a bool
 type PredBinaryApplication struct {
 	*BinaryApplication
 }
 
-// NewCompBinaryApplication returns a new comparison application
+// NewCompBinaryApplication returns a new comparison application. If left
+// and right are both literals, it is folded to a literal bool immediately.
 func NewCompBinaryApplication(left, right Expression, rawOperator string) (Predicate, error) {
 	operator, err := NewCompOperator(rawOperator)
 	if err != nil {
 		return nil, err
 	}
-	return &PredBinaryApplication{
+	pred := &PredBinaryApplication{
 		BinaryApplication: &BinaryApplication{
 			Left:     left,
 			Right:    right,
 			Operator: operator,
 		},
-	}, nil
+	}
+	if isConstantExpr(left) && isConstantExpr(right) {
+		return foldPredicate(pred), nil
+	}
+	return pred, nil
 }
 
 // MustNewCompBinaryApplication wraps NewCompBinaryApplication but panics on failure
@@ -387,19 +720,24 @@ func (app *PredBinaryApplication) Equals(rawOther interface{}) bool {
 	return false
 }
 
-// NewBoolBinaryApplication returns a new comparison application
+// NewBoolBinaryApplication returns a new comparison application. If left
+// and right are both literals, it is folded to a literal bool immediately.
 func NewBoolBinaryApplication(left, right Predicate, rawOperator string) (Predicate, error) {
 	operator, err := NewBoolBinOperator(rawOperator)
 	if err != nil {
 		return nil, err
 	}
-	return &PredBinaryApplication{
+	pred := &PredBinaryApplication{
 		BinaryApplication: &BinaryApplication{
 			Left:     left,
 			Right:    right,
 			Operator: operator,
 		},
-	}, nil
+	}
+	if isConstantExpr(left) && isConstantExpr(right) {
+		return foldPredicate(pred), nil
+	}
+	return pred, nil
 }
 
 // MustNewBoolBinaryApplication wraps NewBoolBinaryApplication but panics on failure
@@ -411,16 +749,144 @@ func MustNewBoolBinaryApplication(left, right Predicate, rawOperator string) Pre
 	return pred
 }
 
-// ExecuteBool evaluates the value and converts the result to a bool
-func (app *PredBinaryApplication) ExecuteBool(env *Env) (bool, error) {
-	resValue, err := app.BinaryApplication.Execute(env)
-	if err != nil {
+// ExecuteBool evaluates the application as a bool
+func (app *PredBinaryApplication) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	return app.BinaryApplication.EvalBool(ctx, env)
+}
+
+// LogicAndApplication is a boolean AND of two predicates. Unlike
+// PredBinaryApplication built from NewBoolBinaryApplication, ExecuteBool
+// short-circuits: Right is only evaluated when Left is true, so guards
+// such as "tx.to IS CONTRACT and contract.balance > X" skip the
+// (possibly RPC-backed) right-hand side for EOA calls
+type LogicAndApplication struct {
+	Left  Predicate
+	Right Predicate
+}
+
+// NewLogicAndApplication returns a short-circuiting AND predicate. Fully
+// constant applications are folded to their result immediately; "x AND
+// true"/"true AND x" fold to x without waiting for x to also be constant,
+// since the result is x's value either way.
+func NewLogicAndApplication(left, right Predicate) Predicate {
+	if isConstantExpr(left) && isConstantExpr(right) {
+		return foldPredicate(&LogicAndApplication{Left: left, Right: right})
+	}
+	if isBoolLiteral(left, true) {
+		return right
+	}
+	if isBoolLiteral(right, true) {
+		return left
+	}
+	return &LogicAndApplication{Left: left, Right: right}
+}
+
+func (a *LogicAndApplication) String() string {
+	return fmt.Sprintf("(and %v %v)", a.Left, a.Right)
+}
+
+// Equals returns true if the application equals other
+func (a *LogicAndApplication) Equals(rawOther interface{}) bool {
+	if other, ok := rawOther.(*LogicAndApplication); ok {
+		return a.Left.Equals(other.Left) && a.Right.Equals(other.Right)
+	}
+	return false
+}
+
+// ExecuteBool evaluates Left, only evaluating Right when Left is true
+func (a *LogicAndApplication) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	left, err := a.Left.ExecuteBool(ctx, env)
+	if err != nil || !left {
 		return false, err
 	}
-	if resValue.IsBool() {
-		return resValue.ToBool(), nil
+	return a.Right.ExecuteBool(ctx, env)
+}
+
+// Execute wraps ExecuteBool to make LogicAndApplication compatible with Expression
+func (a *LogicAndApplication) Execute(ctx context.Context, env *Env) (Value, error) {
+	res, err := a.ExecuteBool(ctx, env)
+	return NewBoolValue(res), err
+}
+
+// EvalInt always fails: AND evaluates to a bool
+func (a *LogicAndApplication) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("%v does not evaluate to an int", a)
+}
+
+// EvalBool wraps ExecuteBool so LogicAndApplication satisfies Expression
+func (a *LogicAndApplication) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return a.ExecuteBool(ctx, env)
+}
+
+// EvalString always fails: AND evaluates to a bool
+func (a *LogicAndApplication) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("%v does not evaluate to a string", a)
+}
+
+// LogicOrApplication is the short-circuiting OR counterpart of
+// LogicAndApplication: Right is only evaluated when Left is false
+type LogicOrApplication struct {
+	Left  Predicate
+	Right Predicate
+}
+
+// NewLogicOrApplication returns a short-circuiting OR predicate. Fully
+// constant applications are folded to their result immediately; "x OR
+// false"/"false OR x" fold to x without waiting for x to also be
+// constant, since the result is x's value either way.
+func NewLogicOrApplication(left, right Predicate) Predicate {
+	if isConstantExpr(left) && isConstantExpr(right) {
+		return foldPredicate(&LogicOrApplication{Left: left, Right: right})
+	}
+	if isBoolLiteral(left, false) {
+		return right
+	}
+	if isBoolLiteral(right, false) {
+		return left
+	}
+	return &LogicOrApplication{Left: left, Right: right}
+}
+
+func (a *LogicOrApplication) String() string {
+	return fmt.Sprintf("(or %v %v)", a.Left, a.Right)
+}
+
+// Equals returns true if the application equals other
+func (a *LogicOrApplication) Equals(rawOther interface{}) bool {
+	if other, ok := rawOther.(*LogicOrApplication); ok {
+		return a.Left.Equals(other.Left) && a.Right.Equals(other.Right)
+	}
+	return false
+}
+
+// ExecuteBool evaluates Left, only evaluating Right when Left is false
+func (a *LogicOrApplication) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	left, err := a.Left.ExecuteBool(ctx, env)
+	if err != nil || left {
+		return left, err
 	}
-	return false, fmt.Errorf("expected bool but returned %v", resValue)
+	return a.Right.ExecuteBool(ctx, env)
+}
+
+// Execute wraps ExecuteBool to make LogicOrApplication compatible with Expression
+func (a *LogicOrApplication) Execute(ctx context.Context, env *Env) (Value, error) {
+	res, err := a.ExecuteBool(ctx, env)
+	return NewBoolValue(res), err
+}
+
+// EvalInt always fails: OR evaluates to a bool
+func (a *LogicOrApplication) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("%v does not evaluate to an int", a)
+}
+
+// EvalBool wraps ExecuteBool so LogicOrApplication satisfies Expression
+func (a *LogicOrApplication) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return a.ExecuteBool(ctx, env)
+}
+
+// EvalString always fails: OR evaluates to a bool
+func (a *LogicOrApplication) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("%v does not evaluate to a string", a)
 }
 
 // UnaryApplication is a nary application of an expression
@@ -431,16 +897,21 @@ type UnaryApplication struct {
 }
 
 // NewIntUnaryApplication returns a new unary application from
-// the operand and the raw symbol for the operator
+// the operand and the raw symbol for the operator. A literal operand is
+// folded to its result immediately.
 func NewIntUnaryApplication(operand Expression, rawOperator string) (Expression, error) {
 	operator, err := NewIntUnaryOperator(rawOperator)
 	if err != nil {
 		return nil, err
 	}
-	return &UnaryApplication{
+	app := &UnaryApplication{
 		Operand:  operand,
 		Operator: operator,
-	}, nil
+	}
+	if isConstantExpr(operand) {
+		return foldExpression(app), nil
+	}
+	return app, nil
 }
 
 // MustNewIntUnaryApplication is similar to NewUnaryApplication but panics on failure
@@ -464,32 +935,80 @@ func (a *UnaryApplication) Equals(rawOther interface{}) bool {
 	return false
 }
 
-// Execute evaluates the operand and applies the operator
-func (a *UnaryApplication) Execute(env *Env) (Value, error) {
-	operand, err := a.Operand.Execute(env)
+// apply evaluates the operand through the operator's static operand type
+// and applies the operator
+func (a *UnaryApplication) apply(ctx context.Context, env *Env) (Value, error) {
+	operand, err := evalOperand(ctx, env, a.Operand, a.Operator.OperandType())
 	if err != nil {
 		return nil, err
 	}
 	return a.Operator.Apply(operand)
 }
 
+// Execute evaluates the operand and applies the operator
+func (a *UnaryApplication) Execute(ctx context.Context, env *Env) (Value, error) {
+	return a.apply(ctx, env)
+}
+
+// EvalInt evaluates the application as an int; valid only when the
+// operator's static result type is int
+func (a *UnaryApplication) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	if a.Operator.ResultType() != IntValueType {
+		return nil, fmt.Errorf("%v does not evaluate to an int", a)
+	}
+	result, err := a.apply(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	return result.ToInt(), nil
+}
+
+// EvalBool evaluates the application as a bool; valid only when the
+// operator's static result type is bool (e.g. NOT)
+func (a *UnaryApplication) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	if a.Operator.ResultType() != BoolValueType {
+		return false, fmt.Errorf("%v does not evaluate to a bool", a)
+	}
+	result, err := a.apply(ctx, env)
+	if err != nil {
+		return false, err
+	}
+	return result.ToBool(), nil
+}
+
+// EvalString always fails: no unary operator produces a string
+func (a *UnaryApplication) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("%v does not evaluate to a string", a)
+}
+
 // PredUnaryApplication is a unary application which evaluates to a bool
 type PredUnaryApplication struct {
 	*UnaryApplication
 }
 
-// NewPredUnaryApplication returns a new application evaluating to bool
+// NewPredUnaryApplication returns a new application evaluating to bool.
+// "not not x" is simplified to x directly, and a literal operand is
+// folded to its result immediately.
 func NewPredUnaryApplication(operand Predicate, rawOperator string) (Predicate, error) {
 	operator, err := NewBoolUnaryOperator(rawOperator)
 	if err != nil {
 		return nil, err
 	}
-	return &PredUnaryApplication{
+	if rawOperator == "not" {
+		if inner, ok := operand.(*PredUnaryApplication); ok && inner.Operator.Equals(operator) {
+			return inner.UnaryApplication.Operand.(Predicate), nil
+		}
+	}
+	pred := &PredUnaryApplication{
 		UnaryApplication: &UnaryApplication{
 			Operand:  operand,
 			Operator: operator,
 		},
-	}, nil
+	}
+	if isConstantExpr(operand) {
+		return foldPredicate(pred), nil
+	}
+	return pred, nil
 }
 
 // NegatePredicate is a helper to create a unary application with NOT as an operator
@@ -510,15 +1029,8 @@ func (a *PredUnaryApplication) Equals(rawOther interface{}) bool {
 }
 
 // ExecuteBool evaluates the value and converts the result to a bool
-func (a *PredUnaryApplication) ExecuteBool(env *Env) (bool, error) {
-	resValue, err := a.UnaryApplication.Execute(env)
-	if err != nil {
-		return false, err
-	}
-	if resValue.IsBool() {
-		return resValue.ToBool(), nil
-	}
-	return false, fmt.Errorf("expected bool but returned %v", resValue.Raw())
+func (a *PredUnaryApplication) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	return a.UnaryApplication.EvalBool(ctx, env)
 }
 
 // InOperator is EMQL IN operator: exp in (e1, e2, e3)
@@ -527,7 +1039,13 @@ type InOperator struct {
 	Haystack []Expression
 }
 
-// NewInOperator returns an IN operator
+// NewInOperator returns an IN operator. Unlike the other Application
+// constructors, this is never folded to a literal bool even when needle
+// and every haystack entry are constant: code such as
+// GroupByExecutor.Results dedupes expressions by Equals, which compares
+// an *InOperator structurally (same needle, same haystack), and folding
+// would collapse two InOperators with a different haystack into the same
+// literal bool whenever they happen to evaluate the same way.
 func NewInOperator(needle Expression, haystack []Expression) Predicate {
 	return &InOperator{
 		Needle:   needle,
@@ -563,22 +1081,19 @@ func (op *InOperator) String() string {
 	return fmt.Sprintf("(in %v)", strings.Join(args, " "))
 }
 
-// ExecuteBool executes all the expressions and checks if lhs
-// is inlcuded in rhs
-func (op *InOperator) ExecuteBool(env *Env) (bool, error) {
-	lhs, err := op.Needle.Execute(env)
+// ExecuteBool evaluates needle, then evaluates the haystack lazily,
+// returning as soon as an entry matches instead of first evaluating and
+// materializing every value
+func (op *InOperator) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	lhs, err := op.Needle.Execute(ctx, env)
 	if err != nil {
 		return false, err
 	}
-	var rhs []interface{}
 	for _, exp := range op.Haystack {
-		val, err := exp.Execute(env)
+		val, err := exp.Execute(ctx, env)
 		if err != nil {
 			return false, err
 		}
-		rhs = append(rhs, val)
-	}
-	for _, val := range rhs {
 		if lhs.Equals(val) {
 			return true, nil
 		}
@@ -587,12 +1102,30 @@ func (op *InOperator) ExecuteBool(env *Env) (bool, error) {
 }
 
 // Execute wraps ExecuteBool to make the function compatible with Expression
-func (op *InOperator) Execute(env *Env) (Value, error) {
-	res, err := op.ExecuteBool(env)
+func (op *InOperator) Execute(ctx context.Context, env *Env) (Value, error) {
+	res, err := op.ExecuteBool(ctx, env)
 	return NewBoolValue(res), err
 }
 
-// IsOperator is the IS operator: exp IS ADDRESS
+// EvalInt always fails: IN evaluates to a bool
+func (op *InOperator) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("%v does not evaluate to an int", op)
+}
+
+// EvalBool wraps ExecuteBool so InOperator satisfies Expression
+func (op *InOperator) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return op.ExecuteBool(ctx, env)
+}
+
+// EvalString always fails: IN evaluates to a bool
+func (op *InOperator) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("%v does not evaluate to a string", op)
+}
+
+// IsOperator is the IS operator: exp IS ADDRESS. Target is matched
+// case-insensitively against ADDRESS, CONTRACT, EOA, ERC20 and ERC721;
+// all but ADDRESS read the operand address's deployed code through the
+// Env's ChainState.
 type IsOperator struct {
 	Operand Expression
 	Target  string
@@ -618,22 +1151,117 @@ func (op *IsOperator) Equals(rawOther interface{}) bool {
 	return false
 }
 
+// erc20Selectors are the 4-byte function selectors a contract's bytecode
+// must embed a PUSH4 of to be heuristically classified as an ERC20 token
+var erc20Selectors = [][4]byte{
+	{0x18, 0x16, 0x0d, 0xdd}, // totalSupply()
+	{0x70, 0xa0, 0x82, 0x31}, // balanceOf(address)
+	{0xa9, 0x05, 0x9c, 0xbb}, // transfer(address,uint256)
+	{0x23, 0xb8, 0x72, 0xdd}, // transferFrom(address,address,uint256)
+	{0x09, 0x5e, 0xa7, 0xb3}, // approve(address,uint256)
+}
+
+// erc721Selectors are the ERC721 equivalent of erc20Selectors
+var erc721Selectors = [][4]byte{
+	{0x70, 0xa0, 0x82, 0x31}, // balanceOf(address)
+	{0x63, 0x52, 0x21, 0x1e}, // ownerOf(uint256)
+	{0x42, 0x84, 0x2e, 0x0e}, // safeTransferFrom(address,address,uint256)
+	{0x09, 0x5e, 0xa7, 0xb3}, // approve(address,uint256)
+	{0xa2, 0x2c, 0xb4, 0x65}, // setApprovalForAll(address,bool)
+}
+
+// hasSelector reports whether code contains a PUSH4 (0x63) of selector,
+// the standard way a Solidity dispatcher matches a call's 4-byte function
+// selector
+func hasSelector(code []byte, selector [4]byte) bool {
+	const push4 = 0x63
+	needle := append([]byte{push4}, selector[:]...)
+	return bytes.Contains(code, needle)
+}
+
+// hasAllSelectors reports whether code embeds every selector in
+// selectors, used to heuristically classify a contract's token standard
+// without an ABI-aware eth_call
+func hasAllSelectors(code []byte, selectors [][4]byte) bool {
+	for _, selector := range selectors {
+		if !hasSelector(code, selector) {
+			return false
+		}
+	}
+	return true
+}
+
+// codeAt returns the deployed bytecode at addr, used by the CONTRACT/EOA/
+// ERC20/ERC721 targets below
+func (op *IsOperator) codeAt(ctx context.Context, env *Env, addr common.Address) ([]byte, error) {
+	if env.Chain == nil {
+		return nil, fmt.Errorf("IS %s requires chain state access", op.Target)
+	}
+	return env.Chain.CodeAt(ctx, addr, env.BlockNumber)
+}
+
 // ExecuteBool returns true if target is fulfilled
-func (op *IsOperator) ExecuteBool(env *Env) (bool, error) {
-	_, err := op.Operand.Execute(env)
+func (op *IsOperator) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	value, err := op.Operand.Execute(ctx, env)
 	if err != nil {
 		return false, err
 	}
-	// TODO: implement me
-	return false, nil
+	addrBytes, err := valueAsBytes(value)
+	if err != nil {
+		return false, fmt.Errorf("IS %s: %v", op.Target, err)
+	}
+	switch strings.ToUpper(op.Target) {
+	case "ADDRESS":
+		return len(addrBytes) == common.AddressLength, nil
+	case "CONTRACT":
+		code, err := op.codeAt(ctx, env, common.BytesToAddress(addrBytes))
+		if err != nil {
+			return false, err
+		}
+		return len(code) > 0, nil
+	case "EOA":
+		code, err := op.codeAt(ctx, env, common.BytesToAddress(addrBytes))
+		if err != nil {
+			return false, err
+		}
+		return len(code) == 0, nil
+	case "ERC20":
+		code, err := op.codeAt(ctx, env, common.BytesToAddress(addrBytes))
+		if err != nil {
+			return false, err
+		}
+		return hasAllSelectors(code, erc20Selectors), nil
+	case "ERC721":
+		code, err := op.codeAt(ctx, env, common.BytesToAddress(addrBytes))
+		if err != nil {
+			return false, err
+		}
+		return hasAllSelectors(code, erc721Selectors), nil
+	}
+	return false, fmt.Errorf("unknown IS target %q", op.Target)
 }
 
 // Execute wraps ExecuteBool
-func (op *IsOperator) Execute(env *Env) (Value, error) {
-	res, err := op.ExecuteBool(env)
+func (op *IsOperator) Execute(ctx context.Context, env *Env) (Value, error) {
+	res, err := op.ExecuteBool(ctx, env)
 	return NewBoolValue(res), err
 }
 
+// EvalInt always fails: IS evaluates to a bool
+func (op *IsOperator) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("%v does not evaluate to an int", op)
+}
+
+// EvalBool wraps ExecuteBool so IsOperator satisfies Expression
+func (op *IsOperator) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return op.ExecuteBool(ctx, env)
+}
+
+// EvalString always fails: IS evaluates to a bool
+func (op *IsOperator) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("%v does not evaluate to a string", op)
+}
+
 // Value is a generic value which can be returned by an expression
 type Value interface {
 	Equals(other interface{}) bool
@@ -641,9 +1269,11 @@ type Value interface {
 	ToBool() bool
 	ToString() string
 	ToInt() *big.Int
+	ToBytes() []byte
 	IsBool() bool
 	IsString() bool
 	IsInt() bool
+	IsBytes() bool
 }
 
 // IntValue is a int wrapper implementing the Expression interface
@@ -669,10 +1299,25 @@ func (i *IntValue) Equals(rawOther interface{}) bool {
 }
 
 // Execute return the value as is
-func (i *IntValue) Execute(env *Env) (Value, error) {
+func (i *IntValue) Execute(ctx context.Context, env *Env) (Value, error) {
 	return i, nil
 }
 
+// EvalInt returns the wrapped int
+func (i *IntValue) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return i.Value, nil
+}
+
+// EvalBool always fails: an int literal is not a bool
+func (i *IntValue) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return false, fmt.Errorf("cannot evaluate int literal %v as bool", i.Value)
+}
+
+// EvalString always fails: an int literal is not a string
+func (i *IntValue) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("cannot evaluate int literal %v as string", i.Value)
+}
+
 // Raw return the raw wrapped value
 func (i *IntValue) Raw() interface{} {
 	return i.Value
@@ -693,6 +1338,11 @@ func (i *IntValue) ToInt() *big.Int {
 	return i.Value
 }
 
+// ToBytes will panic on IntValue
+func (i *IntValue) ToBytes() []byte {
+	panic(fmt.Errorf("cannot convert int to bytes"))
+}
+
 // IsInt is true for IntValue
 func (i *IntValue) IsInt() bool {
 	return true
@@ -708,6 +1358,11 @@ func (i *IntValue) IsString() bool {
 	return false
 }
 
+// IsBytes is false for IntValue
+func (i *IntValue) IsBytes() bool {
+	return false
+}
+
 // StringValue is a string wrapper implementing the Expression interface
 type StringValue struct {
 	Value string
@@ -723,10 +1378,25 @@ func (s *StringValue) String() string {
 }
 
 // Execute return the wrapped value
-func (s *StringValue) Execute(env *Env) (Value, error) {
+func (s *StringValue) Execute(ctx context.Context, env *Env) (Value, error) {
 	return s, nil
 }
 
+// EvalInt always fails: a string literal is not an int
+func (s *StringValue) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("cannot evaluate string literal %q as int", s.Value)
+}
+
+// EvalBool always fails: a string literal is not a bool
+func (s *StringValue) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return false, fmt.Errorf("cannot evaluate string literal %q as bool", s.Value)
+}
+
+// EvalString returns the wrapped string
+func (s *StringValue) EvalString(ctx context.Context, env *Env) (string, error) {
+	return s.Value, nil
+}
+
 // Equals returns true if the value equals other
 func (s *StringValue) Equals(rawOther interface{}) bool {
 	if other, ok := rawOther.(*StringValue); ok {
@@ -755,6 +1425,11 @@ func (s *StringValue) ToInt() *big.Int {
 	panic(fmt.Errorf("cannot convert string to int"))
 }
 
+// ToBytes will panic on StringValue
+func (s *StringValue) ToBytes() []byte {
+	panic(fmt.Errorf("cannot convert string to bytes"))
+}
+
 // IsInt is false for StringValue
 func (s *StringValue) IsInt() bool {
 	return false
@@ -770,6 +1445,11 @@ func (s *StringValue) IsString() bool {
 	return true
 }
 
+// IsBytes is false for StringValue
+func (s *StringValue) IsBytes() bool {
+	return false
+}
+
 // BoolValue is a bool wrapper implementing the Expression and Value interfaces
 type BoolValue struct {
 	Value bool
@@ -785,15 +1465,30 @@ func (b *BoolValue) String() string {
 }
 
 // Execute return the wrapped value
-func (b *BoolValue) Execute(env *Env) (Value, error) {
+func (b *BoolValue) Execute(ctx context.Context, env *Env) (Value, error) {
 	return b, nil
 }
 
 // ExecuteBool makes bool implement the predicate interface
-func (b *BoolValue) ExecuteBool(env *Env) (bool, error) {
+func (b *BoolValue) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	return b.Value, nil
+}
+
+// EvalInt always fails: a bool literal is not an int
+func (b *BoolValue) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return nil, fmt.Errorf("cannot evaluate bool literal %v as int", b.Value)
+}
+
+// EvalBool returns the wrapped bool
+func (b *BoolValue) EvalBool(ctx context.Context, env *Env) (bool, error) {
 	return b.Value, nil
 }
 
+// EvalString always fails: a bool literal is not a string
+func (b *BoolValue) EvalString(ctx context.Context, env *Env) (string, error) {
+	return "", fmt.Errorf("cannot evaluate bool literal %v as string", b.Value)
+}
+
 // Equals returns true if the value equals other
 func (b *BoolValue) Equals(rawOther interface{}) bool {
 	if other, ok := rawOther.(*BoolValue); ok {
@@ -822,6 +1517,11 @@ func (b *BoolValue) ToInt() *big.Int {
 	panic(fmt.Errorf("cannot convert bool to int"))
 }
 
+// ToBytes will panic on BoolValue
+func (b *BoolValue) ToBytes() []byte {
+	panic(fmt.Errorf("cannot convert bool to bytes"))
+}
+
 // IsInt is false for BoolValue
 func (b *BoolValue) IsInt() bool {
 	return false
@@ -837,18 +1537,126 @@ func (b *BoolValue) IsString() bool {
 	return false
 }
 
+// IsBytes is false for BoolValue
+func (b *BoolValue) IsBytes() bool {
+	return false
+}
+
+// BytesValue is a raw bytes wrapper implementing the Value interface. It is
+// produced by builtins such as keccak256() or addr() rather than parsed
+// from EMQL source directly, so unlike IntValue/StringValue/BoolValue it
+// does not implement Expression.
+type BytesValue struct {
+	Value []byte
+}
+
+// NewBytesValue constructs a new bytes value
+func NewBytesValue(value []byte) *BytesValue {
+	return &BytesValue{Value: value}
+}
+
+func (v *BytesValue) String() string {
+	return fmt.Sprintf("0x%x", v.Value)
+}
+
+// Equals returns true if the value equals other
+func (v *BytesValue) Equals(rawOther interface{}) bool {
+	if other, ok := rawOther.(*BytesValue); ok {
+		return bytes.Equal(v.Value, other.Value)
+	}
+	return false
+}
+
+// Raw return the raw wrapped value
+func (v *BytesValue) Raw() interface{} {
+	return v.Value
+}
+
+// ToBool will panic on BytesValue
+func (v *BytesValue) ToBool() bool {
+	panic(fmt.Errorf("cannot convert bytes to bool"))
+}
+
+// ToString will panic on BytesValue
+func (v *BytesValue) ToString() string {
+	panic(fmt.Errorf("cannot convert bytes to string"))
+}
+
+// ToInt will panic on BytesValue
+func (v *BytesValue) ToInt() *big.Int {
+	panic(fmt.Errorf("cannot convert bytes to int"))
+}
+
+// ToBytes will return the underlying bytes
+func (v *BytesValue) ToBytes() []byte {
+	return v.Value
+}
+
+// IsInt is false for BytesValue
+func (v *BytesValue) IsInt() bool {
+	return false
+}
+
+// IsBool is false for BytesValue
+func (v *BytesValue) IsBool() bool {
+	return false
+}
+
+// IsString is false for BytesValue
+func (v *BytesValue) IsString() bool {
+	return false
+}
+
+// IsBytes is true for BytesValue
+func (v *BytesValue) IsBytes() bool {
+	return true
+}
+
 // FunctionCall represents a function call and implements Expression
 type FunctionCall struct {
 	FunctionName string
 	Arguments    []Expression
-}
-
-// NewFunctionCall returns a new function call
-func NewFunctionCall(name string, arguments []Expression) *FunctionCall {
-	return &FunctionCall{
+	// builtin caches FunctionName's registered Callable, resolved once
+	// here rather than through a LookupBuiltin call on every Execute. nil
+	// when FunctionName isn't (yet) registered as a builtin, e.g. an
+	// aggregate handled separately by GroupByClause.
+	builtin Callable
+}
+
+// NewFunctionCall returns a new function call. When name matches a builtin
+// registered through RegisterBuiltin, arity and (where the argument's
+// static type is already known) parameter types are validated eagerly, so
+// a typo'd or misused builtin call fails at parse time rather than when
+// the alert eventually fires. Names not found in the registry (e.g. the
+// aggregate functions handled separately by GroupByClause) are left for
+// Execute to resolve at evaluation time.
+func NewFunctionCall(name string, arguments []Expression) (Expression, error) {
+	f := &FunctionCall{
 		FunctionName: strings.ToLower(name),
 		Arguments:    arguments,
 	}
+	builtin, exists := LookupBuiltin(f.FunctionName)
+	if !exists {
+		return f, nil
+	}
+	if err := validateCall(builtin, arguments); err != nil {
+		return nil, err
+	}
+	f.builtin = builtin
+	if builtin.Pure() && allConstantExprs(arguments) {
+		return foldExpression(f), nil
+	}
+	return f, nil
+}
+
+// MustNewFunctionCall does the same thing as NewFunctionCall but panics on
+// error
+func MustNewFunctionCall(name string, arguments []Expression) Expression {
+	f, err := NewFunctionCall(name, arguments)
+	if err != nil {
+		panic(err)
+	}
+	return f
 }
 
 func (f *FunctionCall) String() string {
@@ -879,49 +1687,279 @@ func (f *FunctionCall) Equals(rawOther interface{}) bool {
 	return true
 }
 
-// Execute evaluates all the arguments of the function
-// and calls the function
-func (f *FunctionCall) Execute(env *Env) (Value, error) {
+// Execute evaluates all the arguments of the function and calls it.
+// env.Functions is checked first so callers can shadow or mock a builtin
+// for a single environment; otherwise the call is dispatched to the
+// package-level builtin registry, falling back to env.ExecuteFunction's
+// "unknown function" error when neither knows the name.
+func (f *FunctionCall) Execute(ctx context.Context, env *Env) (Value, error) {
 	var evaluatedArguments []Value
 	for _, argument := range f.Arguments {
-		result, err := argument.Execute(env)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("evaluating arguments to %s: %v", f.FunctionName, err)
+		}
+		result, err := argument.Execute(ctx, env)
 		if err != nil {
 			return nil, err
 		}
 		evaluatedArguments = append(evaluatedArguments, result)
 	}
-	return env.ExecuteFunction(f.FunctionName, evaluatedArguments...)
+	if _, overridden := env.Functions[f.FunctionName]; overridden {
+		return env.ExecuteFunction(ctx, f.FunctionName, evaluatedArguments...)
+	}
+	if f.builtin != nil {
+		return f.builtin.Call(ctx, env, evaluatedArguments)
+	}
+	return env.ExecuteFunction(ctx, f.FunctionName, evaluatedArguments...)
+}
+
+// EvalInt calls the function and converts the result to an int. Execute
+// may dispatch to an env.Functions override whose result type is not
+// known statically, so this goes through Execute and Value
+func (f *FunctionCall) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	value, err := f.Execute(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	return valueAsInt(value)
+}
+
+// EvalBool is the bool equivalent of EvalInt
+func (f *FunctionCall) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	value, err := f.Execute(ctx, env)
+	if err != nil {
+		return false, err
+	}
+	return valueAsBool(value)
+}
+
+// EvalString is the string equivalent of EvalInt
+func (f *FunctionCall) EvalString(ctx context.Context, env *Env) (string, error) {
+	value, err := f.Execute(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	return valueAsString(value)
+}
+
+// WindowSpec is the OVER clause of a windowed aggregate, e.g.
+// "over (partition by tx.from rows between 10 preceding and current row)".
+// PartitionBy is empty for a single window spanning the whole stream.
+type WindowSpec struct {
+	PartitionBy []Expression
+	// Preceding is the number of rows, not counting the current one, that
+	// stay in the window. The frame is always anchored at the current
+	// row: EMQL only supports "rows between N preceding and current row".
+	Preceding int64
+}
+
+// WindowFunctionCall applies an aggregate (COUNT, SUM, ...) over a bounded
+// window of the most recent rows in its partition, re-deriving the
+// aggregate from scratch from the window's buffered rows on every
+// evaluation rather than maintaining incremental running state (unlike
+// GroupByExecutor, whose Aggregators fold each row in as it arrives).
+// Evaluating a WindowFunctionCall requires env.Windows (see Env), lazily
+// populated with one WindowExecutor per distinct window function call in
+// a statement.
+type WindowFunctionCall struct {
+	FunctionName string
+	Argument     Expression
+	Window       *WindowSpec
+}
+
+// NewWindowFunctionCall validates that name is a registered aggregate and
+// returns a WindowFunctionCall ready to Execute against rows streamed
+// through its owning WindowExecutor.
+func NewWindowFunctionCall(name string, argument Expression, window *WindowSpec) (*WindowFunctionCall, error) {
+	lowered := strings.ToLower(name)
+	if _, exists := LookupAggregate(lowered); !exists {
+		return nil, fmt.Errorf("%s is not a registered aggregate function", lowered)
+	}
+	return &WindowFunctionCall{FunctionName: lowered, Argument: argument, Window: window}, nil
+}
+
+func (w *WindowFunctionCall) String() string {
+	return fmt.Sprintf("(%s %v over %v)", w.FunctionName, w.Argument, w.Window)
+}
+
+// Equals returns true if the value is equal to other
+func (w *WindowFunctionCall) Equals(rawOther interface{}) bool {
+	other, ok := rawOther.(*WindowFunctionCall)
+	if !ok {
+		return false
+	}
+	if w.FunctionName != other.FunctionName || !w.Argument.Equals(other.Argument) {
+		return false
+	}
+	if w.Window.Preceding != other.Window.Preceding || len(w.Window.PartitionBy) != len(other.Window.PartitionBy) {
+		return false
+	}
+	for i, expr := range w.Window.PartitionBy {
+		if !expr.Equals(other.Window.PartitionBy[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Execute resolves this call's WindowExecutor from env.Windows (creating
+// it on first sight, keyed by w.String()), feeds env.Row into it and
+// returns the aggregate's value over the current window.
+func (w *WindowFunctionCall) Execute(ctx context.Context, env *Env) (Value, error) {
+	executor := env.windowExecutorFor(w)
+	return executor.Add(ctx, env, env.Row)
+}
+
+// EvalInt calls the window function and converts the result to an int
+func (w *WindowFunctionCall) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	value, err := w.Execute(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	return valueAsInt(value)
+}
+
+// EvalBool is the bool equivalent of EvalInt
+func (w *WindowFunctionCall) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	value, err := w.Execute(ctx, env)
+	if err != nil {
+		return false, err
+	}
+	return valueAsBool(value)
+}
+
+// EvalString is the string equivalent of EvalInt
+func (w *WindowFunctionCall) EvalString(ctx context.Context, env *Env) (string, error) {
+	value, err := w.Execute(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	return valueAsString(value)
 }
 
-// FromClause is a from clause of a statement
+// FromSource is a single source in a FROM clause, optionally given an
+// alias so it can be referenced by joined predicates (e.g. "a.msg.value").
+// Exactly one of Address, Subquery or CTEName is set: a literal address,
+// a parenthesized subquery, or a reference to a WITH clause/view resolved
+// by name (see ResolveSources).
+type FromSource struct {
+	Address *big.Int
+	Alias   string
+	// Param is the bind-parameter name (without its leading '?') this
+	// source was declared with, e.g. "address" for "FROM ?address a".
+	// Address is nil until PreparedStatement.Bind resolves it; empty for
+	// a literal source.
+	Param string
+	// Subquery holds a nested SELECT when this source is a parenthesized
+	// subquery, e.g. "FROM (select ... from 0x...) as t"
+	Subquery *SelectStatement
+	// CTEName is set instead of Address/Subquery when this source refers
+	// to a WITH clause binding or a CREATE VIEW by name. ResolveSources
+	// replaces it with the matching Subquery during planning.
+	CTEName string
+}
+
+// JoinClause correlates a further FromSource with the rest of the FROM
+// clause through an equality predicate, e.g.
+// FROM 0xA a JOIN 0xB b ON a.tx.hash = b.tx.hash
+type JoinClause struct {
+	Source FromSource
+	On     Predicate
+}
+
+// FromClause is a from clause of a statement. Address is kept for backward
+// compatibility and mirrors Sources[0].Address; Sources lists every address
+// in the clause (the primary one, plus any comma-separated or JOINed ones).
 type FromClause struct {
-	// NOTE: can currently only be an address
 	Address *big.Int
+	Sources []FromSource
+	Joins   []JoinClause
+}
+
+// ResolveAlias returns the address registered under alias, and whether it
+// was found
+func (f *FromClause) ResolveAlias(alias string) (*big.Int, bool) {
+	for _, source := range f.Sources {
+		if source.Alias == alias {
+			return source.Address, true
+		}
+	}
+	return nil, false
 }
 
 // Predicate is a node of the AST which should return a boolean when executed
 type Predicate interface {
 	Expression
-	ExecuteBool(env *Env) (bool, error)
+	ExecuteBool(ctx context.Context, env *Env) (bool, error)
+}
+
+// BlockRef is a reference to a position in the chain, used by SINCE/UNTIL
+// clauses. It is either an explicit block number, or a timestamp/duration
+// which must be resolved to a block number before the query can run.
+type BlockRef struct {
+	BlockNum  int64
+	Timestamp *int64
+	Duration  *time.Duration
+	// Param is the bind-parameter name this ref was declared with, e.g.
+	// "since" for "SINCE ?since". Only PreparedStatement.Bind resolves
+	// it, into an explicit-block-number BlockRef; empty for a literal
+	// BlockRef.
+	Param string
 }
 
-// LimitClause is a limit clause
-type LimitClause struct {
-	Limit int64
+// NewBlockRef returns a BlockRef pinned to an explicit block number
+func NewBlockRef(blockNum int64) *BlockRef {
+	return &BlockRef{BlockNum: blockNum}
 }
 
-// SinceClause is a since clause
-type SinceClause struct {
-	Since int64
+// NewTimestampBlockRef returns a BlockRef which must be resolved to a block
+// number from the given unix timestamp before the query can be evaluated
+func NewTimestampBlockRef(timestamp int64) *BlockRef {
+	return &BlockRef{Timestamp: &timestamp}
 }
 
-// UntilClause is an until clause
-type UntilClause struct {
-	Until int64
+// NewDurationBlockRef returns a BlockRef which must be resolved relative to
+// the current time minus duration before the query can be evaluated
+func NewDurationBlockRef(duration time.Duration) *BlockRef {
+	return &BlockRef{Duration: &duration}
+}
+
+// NeedsResolution returns true if BlockNum is not known yet and the ref
+// must first be resolved against the chain's header store
+func (b *BlockRef) NeedsResolution() bool {
+	return b.Timestamp != nil || b.Duration != nil
 }
 
 // GroupByClause is a group by clause
 type GroupByClause struct {
+	BlocksCount       *int64
+	TransactionsCount *int64
+	Attributes        []*Attribute
+}
+
+// NewGroupByClause returns an empty GroupByClause
+func NewGroupByClause() *GroupByClause {
+	return &GroupByClause{}
+}
+
+// OrderByElem is a single ORDER BY term: an expression plus its sort
+// direction
+type OrderByElem struct {
+	Expr Expression
+	Desc bool
+}
+
+// CTEDefinition is a single named subquery declared in a statement's
+// WITH clause, e.g. "recent AS (select ... from 0x...)". A later CTE in
+// the same WITH clause may itself reference an earlier one by name.
+type CTEDefinition struct {
+	Name  string
+	Query *SelectStatement
+}
+
+// Statement is any top-level EMQL statement ParseStatement can produce
+type Statement interface {
+	statementNode()
 }
 
 // SelectStatement is a full EMQL select statement
@@ -929,9 +1967,75 @@ type SelectStatement struct {
 	Selected []Expression
 	From     *FromClause
 	Where    Predicate
-	Limit    LimitClause
-	Since    SinceClause
-	Until    UntilClause
-	GroupBy  GroupByClause
-	Aliases  map[string]Expression
+	Limit    *int64
+	Offset   *int64
+	Since    *BlockRef
+	Until    *BlockRef
+	GroupBy  *GroupByClause
+	// Having filters post-aggregation groups, evaluated the same way as
+	// Where but against a group's already-aggregated row
+	Having Predicate
+	// OrderBy sorts the projected rows, applied after GroupBy/Having and
+	// before Limit/Offset
+	OrderBy []OrderByElem
+	Aliases map[string]Expression
+	// LimitParam is the bind-parameter name LIMIT was declared with, e.g.
+	// "limit" for "LIMIT ?limit". Only PreparedStatement.Bind resolves it
+	// into Limit; empty when LIMIT is a literal or absent.
+	LimitParam string
+	// With holds this statement's own WITH-clause CTE definitions, if
+	// any. A FromSource naming a CTE (FromSource.CTEName) is resolved
+	// against these, falling back to the Alerter's persisted views; see
+	// ResolveSources.
+	With []CTEDefinition
+}
+
+func (s *SelectStatement) statementNode() {}
+
+// EvaluateContext evaluates s's WHERE clause (if any) against env, honoring
+// ctx cancellation the same way matchesStreamingQuery and
+// PreparedStatement.Execute do, and projects the Selected row (with
+// Aliases applied) when it matches. It does not itself resolve GroupBy,
+// Having, OrderBy, Limit or Offset; those are applied by the caller once
+// a full set of rows is available (see GroupByExecutor/WindowExecutor for
+// GroupBy/window functions, and ORDER BY/LIMIT/OFFSET are applied by the
+// caller against the resulting row set).
+func (s *SelectStatement) EvaluateContext(ctx context.Context, env *Env) (Row, bool, error) {
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+
+	matched := true
+	var err error
+	if s.Where != nil {
+		if matched, err = s.Where.ExecuteBool(ctx, env); err != nil {
+			return nil, false, err
+		}
+	}
+	if !matched {
+		return nil, false, nil
+	}
+
+	row := make(Row, len(s.Selected))
+	for _, expression := range s.Selected {
+		value, err := expression.Execute(ctx, env)
+		if err != nil {
+			return nil, false, err
+		}
+		row[expression.String()] = value
+	}
+	for alias, expression := range s.Aliases {
+		row[alias] = row[expression.String()]
+	}
+	return row, true, nil
 }
+
+// CreateViewStatement is a parsed "CREATE VIEW name AS select ..."
+// statement. RegisterView persists it so later queries can FROM it by
+// name the same way they reference a CTE.
+type CreateViewStatement struct {
+	Name  string
+	Query *SelectStatement
+}
+
+func (s *CreateViewStatement) statementNode() {}
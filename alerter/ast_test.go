@@ -1,6 +1,7 @@
 package alerter
 
 import (
+	"context"
 	"math/big"
 	"testing"
 
@@ -48,6 +49,49 @@ func TestBinaryOperatorEquals(t *testing.T) {
 	}
 }
 
+func TestBitwiseAndShiftOperators(t *testing.T) {
+	testCases := []struct {
+		op       string
+		left     int64
+		right    int64
+		expected int64
+	}{
+		{"&", 0xff, 0x0f, 0x0f},
+		{"|", 0xf0, 0x0f, 0xff},
+		{"^", 0xff, 0x0f, 0xf0},
+		{"<<", 1, 8, 256},
+		{">>", 256, 8, 1},
+	}
+	for _, testCase := range testCases {
+		op, err := NewIntBinOperator(testCase.op)
+		assert.Nil(t, err)
+		result, err := op.Apply(NewIntValue(big.NewInt(testCase.left)), NewIntValue(big.NewInt(testCase.right)))
+		assert.Nil(t, err)
+		assert.Equal(t, big.NewInt(testCase.expected), result.ToInt(),
+			"failed with %d %s %d", testCase.left, testCase.op, testCase.right)
+	}
+
+	notOp, err := NewIntUnaryOperator("~")
+	assert.Nil(t, err)
+	result, err := notOp.Apply(NewIntValue(big.NewInt(0)))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(-1), result.ToInt())
+}
+
+func TestShiftAmountBounds(t *testing.T) {
+	op, err := NewIntBinOperator("<<")
+	assert.Nil(t, err)
+
+	_, err = op.Apply(NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(-1)))
+	assert.NotNil(t, err)
+
+	_, err = op.Apply(NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(maxShiftAmount+1)))
+	assert.NotNil(t, err)
+
+	_, err = op.Apply(NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(maxShiftAmount)))
+	assert.Nil(t, err)
+}
+
 func TestIntValueEquals(t *testing.T) {
 	testCases := []struct {
 		value    Expression
@@ -228,3 +272,54 @@ func TestPredUnaryApplicationEquals(t *testing.T) {
 			"failed with %v %v, expected %v", testCase.value, testCase.other, testCase.expected)
 	}
 }
+
+func TestTypedEvaluators(t *testing.T) {
+	sum := MustNewIntBinaryApplication(NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(2)), "+")
+	sumValue, err := sum.EvalInt(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(3), sumValue)
+	_, err = sum.EvalBool(context.Background(), nil)
+	assert.NotNil(t, err)
+	_, err = sum.EvalString(context.Background(), nil)
+	assert.NotNil(t, err)
+
+	comparison := MustNewCompBinaryApplication(NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(2)), "<")
+	comparisonValue, err := comparison.ExecuteBool(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.True(t, comparisonValue)
+	_, err = comparison.EvalInt(context.Background(), nil)
+	assert.NotNil(t, err)
+
+	negated := MustNewIntUnaryApplication(NewIntValue(big.NewInt(5)), "-")
+	negatedValue, err := negated.EvalInt(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(-5), negatedValue)
+}
+
+func TestLogicShortCircuit(t *testing.T) {
+	// dividing by zero panics if ever evaluated, so these predicates prove
+	// whether the right-hand side was actually reached
+	boom := MustNewCompBinaryApplication(
+		MustNewIntBinaryApplication(one, NewIntValue(big.NewInt(0)), "/"),
+		NewIntValue(big.NewInt(0)),
+		">",
+	)
+
+	and := NewLogicAndApplication(NewBoolValue(false), boom)
+	result, err := and.ExecuteBool(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.False(t, result)
+
+	or := NewLogicOrApplication(NewBoolValue(true), boom)
+	result, err = or.ExecuteBool(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.True(t, result)
+}
+
+func TestInOperatorLazyMatch(t *testing.T) {
+	boom := MustNewIntBinaryApplication(one, NewIntValue(big.NewInt(0)), "/")
+	op := NewInOperator(NewIntValue(big.NewInt(1)), []Expression{NewIntValue(big.NewInt(1)), boom})
+	result, err := op.ExecuteBool(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.True(t, result)
+}
@@ -0,0 +1,289 @@
+package alerter
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// AttributeProvider resolves one top-level Attribute namespace (tx, block,
+// log, contract, ...) into Values. path is an Attribute's Parts with the
+// namespace itself already stripped, e.g. ["topics", "0"] for
+// log.topics.0. LeafType reports the static type of a leaf without
+// touching the underlying chain data, so it can be used for type checking
+// against a zero-value provider before any row has been resolved.
+type AttributeProvider interface {
+	LeafType(path []string) (ValueType, bool)
+	Resolve(ctx context.Context, path []string) (Value, error)
+}
+
+// AttributeResolver resolves Attribute nodes against a registry of
+// namespace providers, caching each resolved value against its full
+// dotted path so that an attribute referenced by more than one predicate
+// in the same evaluation (e.g. WHERE tx.value > 0 AND tx.value < 100) is
+// only resolved once, avoiding repeated RPCs for namespaces such as
+// contract that read chain state.
+type AttributeResolver struct {
+	providers map[string]AttributeProvider
+	cache     map[string]Value
+}
+
+// NewAttributeResolver returns a resolver backed by providers, keyed by
+// their namespace (tx, block, log, contract, ...).
+func NewAttributeResolver(providers map[string]AttributeProvider) *AttributeResolver {
+	return &AttributeResolver{
+		providers: providers,
+		cache:     make(map[string]Value),
+	}
+}
+
+// LeafType returns the static type of the attribute named by parts, e.g.
+// ["tx", "value"], so callers can type-check an Attribute against an
+// operator or builtin parameter ahead of evaluation.
+func (r *AttributeResolver) LeafType(parts []string) (ValueType, bool) {
+	if len(parts) < 2 {
+		return 0, false
+	}
+	provider, exists := r.providers[parts[0]]
+	if !exists {
+		return 0, false
+	}
+	return provider.LeafType(parts[1:])
+}
+
+// Resolve returns the value named by parts, e.g. ["tx", "value"].
+func (r *AttributeResolver) Resolve(ctx context.Context, parts []string) (Value, error) {
+	key := strings.Join(parts, ".")
+	if value, exists := r.cache[key]; exists {
+		return value, nil
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("attribute %q has no namespace", key)
+	}
+	provider, exists := r.providers[parts[0]]
+	if !exists {
+		return nil, fmt.Errorf("unknown attribute namespace %q", parts[0])
+	}
+	value, err := provider.Resolve(ctx, parts[1:])
+	if err != nil {
+		return nil, err
+	}
+	r.cache[key] = value
+	return value, nil
+}
+
+// attributeSchema is a resolver over zero-value providers, used only for
+// LeafType lookups (none of the providers below touch their underlying
+// Tx/Header/Log/Address fields to answer LeafType), so it can type-check
+// an Attribute's namespace and leaf name before any row has been
+// resolved.
+var attributeSchema = NewAttributeResolver(map[string]AttributeProvider{
+	"tx":       &TxAttributeProvider{},
+	"block":    &BlockAttributeProvider{},
+	"log":      &LogAttributeProvider{},
+	"contract": &ContractAttributeProvider{},
+})
+
+// TxAttributeProvider resolves tx.* attributes against a single
+// transaction. From is the already-recovered sender: recovering it
+// requires a chain-specific Signer, so the caller resolves it once up
+// front rather than threading a Signer through every attribute lookup.
+type TxAttributeProvider struct {
+	Tx   *types.Transaction
+	From common.Address
+}
+
+// LeafType returns the static type of a tx.* leaf
+func (p *TxAttributeProvider) LeafType(path []string) (ValueType, bool) {
+	if len(path) != 1 {
+		return 0, false
+	}
+	switch path[0] {
+	case "hash", "origin", "to", "data":
+		return BytesValueType, true
+	case "value", "gas", "gasprice", "nonce":
+		return IntValueType, true
+	}
+	return 0, false
+}
+
+// Resolve returns the value of a tx.* leaf
+func (p *TxAttributeProvider) Resolve(ctx context.Context, path []string) (Value, error) {
+	if len(path) != 1 {
+		return nil, fmt.Errorf("unknown tx attribute %q", strings.Join(path, "."))
+	}
+	switch path[0] {
+	case "hash":
+		hash := p.Tx.Hash()
+		return NewBytesValue(hash[:]), nil
+	case "origin":
+		return NewBytesValue(p.From.Bytes()), nil
+	case "to":
+		to := p.Tx.To()
+		if to == nil {
+			return NewBytesValue(nil), nil
+		}
+		return NewBytesValue(to.Bytes()), nil
+	case "data":
+		return NewBytesValue(p.Tx.Data()), nil
+	case "value":
+		return NewIntValue(p.Tx.Value()), nil
+	case "gas":
+		return NewIntValue(new(big.Int).SetUint64(p.Tx.Gas())), nil
+	case "gasprice":
+		return NewIntValue(p.Tx.GasPrice()), nil
+	case "nonce":
+		return NewIntValue(new(big.Int).SetUint64(p.Tx.Nonce())), nil
+	}
+	return nil, fmt.Errorf("unknown tx attribute %q", path[0])
+}
+
+// BlockAttributeProvider resolves block.* attributes against a single
+// header.
+type BlockAttributeProvider struct {
+	Header *types.Header
+}
+
+// LeafType returns the static type of a block.* leaf
+func (p *BlockAttributeProvider) LeafType(path []string) (ValueType, bool) {
+	if len(path) != 1 {
+		return 0, false
+	}
+	switch path[0] {
+	case "hash", "parenthash", "miner":
+		return BytesValueType, true
+	case "number", "timestamp", "gaslimit", "gasused":
+		return IntValueType, true
+	}
+	return 0, false
+}
+
+// Resolve returns the value of a block.* leaf
+func (p *BlockAttributeProvider) Resolve(ctx context.Context, path []string) (Value, error) {
+	if len(path) != 1 {
+		return nil, fmt.Errorf("unknown block attribute %q", strings.Join(path, "."))
+	}
+	switch path[0] {
+	case "hash":
+		hash := p.Header.Hash()
+		return NewBytesValue(hash[:]), nil
+	case "parenthash":
+		return NewBytesValue(p.Header.ParentHash[:]), nil
+	case "miner":
+		return NewBytesValue(p.Header.Coinbase.Bytes()), nil
+	case "number":
+		return NewIntValue(p.Header.Number), nil
+	case "timestamp":
+		return NewIntValue(new(big.Int).SetUint64(p.Header.Time)), nil
+	case "gaslimit":
+		return NewIntValue(new(big.Int).SetUint64(p.Header.GasLimit)), nil
+	case "gasused":
+		return NewIntValue(new(big.Int).SetUint64(p.Header.GasUsed)), nil
+	}
+	return nil, fmt.Errorf("unknown block attribute %q", path[0])
+}
+
+// LogAttributeProvider resolves log.* attributes against a single log,
+// including indexed topics such as log.topics.0.
+type LogAttributeProvider struct {
+	Log *types.Log
+}
+
+// LeafType returns the static type of a log.* leaf
+func (p *LogAttributeProvider) LeafType(path []string) (ValueType, bool) {
+	if len(path) == 1 {
+		switch path[0] {
+		case "address", "data":
+			return BytesValueType, true
+		case "index":
+			return IntValueType, true
+		case "removed":
+			return BoolValueType, true
+		}
+	}
+	if len(path) == 2 && path[0] == "topics" {
+		if _, err := strconv.Atoi(path[1]); err == nil {
+			return BytesValueType, true
+		}
+	}
+	return 0, false
+}
+
+// Resolve returns the value of a log.* leaf
+func (p *LogAttributeProvider) Resolve(ctx context.Context, path []string) (Value, error) {
+	if len(path) == 1 {
+		switch path[0] {
+		case "address":
+			return NewBytesValue(p.Log.Address.Bytes()), nil
+		case "data":
+			return NewBytesValue(p.Log.Data), nil
+		case "index":
+			return NewIntValue(new(big.Int).SetUint64(uint64(p.Log.Index))), nil
+		case "removed":
+			return NewBoolValue(p.Log.Removed), nil
+		}
+	}
+	if len(path) == 2 && path[0] == "topics" {
+		index, err := strconv.Atoi(path[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic index %q", path[1])
+		}
+		if index < 0 || index >= len(p.Log.Topics) {
+			return nil, fmt.Errorf("topic index %d out of range: log has %d topic(s)", index, len(p.Log.Topics))
+		}
+		topic := p.Log.Topics[index]
+		return NewBytesValue(topic[:]), nil
+	}
+	return nil, fmt.Errorf("unknown log attribute %q", strings.Join(path, "."))
+}
+
+// ContractAttributeProvider resolves contract.* attributes for Address by
+// reading chain state through Chain at BlockNumber.
+type ContractAttributeProvider struct {
+	Chain       ChainState
+	Address     common.Address
+	BlockNumber *big.Int
+}
+
+// LeafType returns the static type of a contract.* leaf
+func (p *ContractAttributeProvider) LeafType(path []string) (ValueType, bool) {
+	if len(path) != 1 {
+		return 0, false
+	}
+	switch path[0] {
+	case "address", "code":
+		return BytesValueType, true
+	case "balance":
+		return IntValueType, true
+	}
+	return 0, false
+}
+
+// Resolve returns the value of a contract.* leaf
+func (p *ContractAttributeProvider) Resolve(ctx context.Context, path []string) (Value, error) {
+	if len(path) != 1 {
+		return nil, fmt.Errorf("unknown contract attribute %q", strings.Join(path, "."))
+	}
+	switch path[0] {
+	case "address":
+		return NewBytesValue(p.Address.Bytes()), nil
+	case "code":
+		code, err := p.Chain.CodeAt(ctx, p.Address, p.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		return NewBytesValue(code), nil
+	case "balance":
+		balance, err := p.Chain.BalanceAt(ctx, p.Address, p.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		return NewIntValue(balance), nil
+	}
+	return nil, fmt.Errorf("unknown contract attribute %q", path[0])
+}
@@ -0,0 +1,232 @@
+package alerter
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxAttributeProvider(t *testing.T) {
+	to := common.HexToAddress("0x00000000000000000000000000000000000042")
+	from := common.HexToAddress("0x00000000000000000000000000000000000043")
+	tx := types.NewTransaction(7, to, big.NewInt(100), 21000, big.NewInt(1), []byte{0x01})
+	provider := &TxAttributeProvider{Tx: tx, From: from}
+
+	valueType, ok := provider.LeafType([]string{"value"})
+	assert.True(t, ok)
+	assert.Equal(t, IntValueType, valueType)
+
+	value, err := provider.Resolve(context.Background(), []string{"value"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(100)), value)
+
+	origin, err := provider.Resolve(context.Background(), []string{"origin"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewBytesValue(from.Bytes()), origin)
+
+	nonce, err := provider.Resolve(context.Background(), []string{"nonce"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(7)), nonce)
+
+	_, ok = provider.LeafType([]string{"unknown"})
+	assert.False(t, ok)
+}
+
+func TestBlockAttributeProvider(t *testing.T) {
+	header := &types.Header{
+		Number: big.NewInt(1000),
+		Time:   12345,
+	}
+	provider := &BlockAttributeProvider{Header: header}
+
+	number, err := provider.Resolve(context.Background(), []string{"number"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(1000)), number)
+
+	timestamp, err := provider.Resolve(context.Background(), []string{"timestamp"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(12345)), timestamp)
+}
+
+func TestLogAttributeProvider(t *testing.T) {
+	topic := common.HexToHash("0xabcdef")
+	log := &types.Log{
+		Address: common.HexToAddress("0x42"),
+		Topics:  []common.Hash{topic},
+		Data:    []byte{1, 2, 3},
+	}
+	provider := &LogAttributeProvider{Log: log}
+
+	valueType, ok := provider.LeafType([]string{"topics", "0"})
+	assert.True(t, ok)
+	assert.Equal(t, BytesValueType, valueType)
+
+	value, err := provider.Resolve(context.Background(), []string{"topics", "0"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewBytesValue(topic[:]), value)
+
+	_, err = provider.Resolve(context.Background(), []string{"topics", "1"})
+	assert.Error(t, err)
+
+	data, err := provider.Resolve(context.Background(), []string{"data"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewBytesValue([]byte{1, 2, 3}), data)
+}
+
+type fakeChainState struct {
+	code    map[common.Address][]byte
+	balance map[common.Address]*big.Int
+}
+
+func (f *fakeChainState) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return f.code[account], nil
+}
+
+func (f *fakeChainState) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	if balance, ok := f.balance[account]; ok {
+		return balance, nil
+	}
+	return big.NewInt(0), nil
+}
+
+func TestContractAttributeProvider(t *testing.T) {
+	addr := common.HexToAddress("0x42")
+	chain := &fakeChainState{
+		code:    map[common.Address][]byte{addr: {0x60, 0x60}},
+		balance: map[common.Address]*big.Int{addr: big.NewInt(500)},
+	}
+	provider := &ContractAttributeProvider{Chain: chain, Address: addr}
+
+	balance, err := provider.Resolve(context.Background(), []string{"balance"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(500)), balance)
+
+	code, err := provider.Resolve(context.Background(), []string{"code"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewBytesValue([]byte{0x60, 0x60}), code)
+}
+
+func TestAttributeResolverCachesByPath(t *testing.T) {
+	calls := 0
+	provider := &genericAttributeProvider{
+		resolve: func(ctx context.Context, path []string) (Value, error) {
+			calls++
+			return NewIntValue(big.NewInt(1)), nil
+		},
+	}
+	resolver := NewAttributeResolver(map[string]AttributeProvider{"custom": provider})
+
+	_, err := resolver.Resolve(context.Background(), []string{"custom", "value"})
+	assert.NoError(t, err)
+	_, err = resolver.Resolve(context.Background(), []string{"custom", "value"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// genericAttributeProvider lets TestAttributeResolverCachesByPath observe
+// how many times Resolve is actually invoked
+type genericAttributeProvider struct {
+	resolve func(ctx context.Context, path []string) (Value, error)
+}
+
+func (p *genericAttributeProvider) LeafType(path []string) (ValueType, bool) {
+	return IntValueType, true
+}
+
+func (p *genericAttributeProvider) Resolve(ctx context.Context, path []string) (Value, error) {
+	return p.resolve(ctx, path)
+}
+
+func TestAttributeExecuteUsesRowBeforeResolver(t *testing.T) {
+	attr := NewAttribute([]string{"msg", "value"})
+	env := &Env{Row: Row{"msg.value": NewIntValue(big.NewInt(42))}}
+	value, err := attr.Execute(context.Background(), env)
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(42)), value)
+}
+
+func TestAttributeExecuteFallsBackToResolver(t *testing.T) {
+	addr := common.HexToAddress("0x42")
+	chain := &fakeChainState{balance: map[common.Address]*big.Int{addr: big.NewInt(9)}}
+	resolver := NewAttributeResolver(map[string]AttributeProvider{
+		"contract": &ContractAttributeProvider{Chain: chain, Address: addr},
+	})
+	env := &Env{Resolver: resolver}
+
+	attr := NewAttribute([]string{"contract", "balance"})
+	value, err := attr.Execute(context.Background(), env)
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(9)), value)
+}
+
+func TestAttributeExecuteWithoutRowOrResolverErrors(t *testing.T) {
+	attr := NewAttribute([]string{"msg", "value"})
+	_, err := attr.Execute(context.Background(), &Env{})
+	assert.Error(t, err)
+}
+
+func addrOperand(addr common.Address) (Expression, Row) {
+	attr := NewAttribute([]string{"addr"})
+	return attr, Row{"addr": NewBytesValue(addr.Bytes())}
+}
+
+func TestIsOperatorAddress(t *testing.T) {
+	addr := common.HexToAddress("0x42")
+	operand, row := addrOperand(addr)
+	op := NewIsOperator(operand, "address")
+	result, err := op.ExecuteBool(context.Background(), &Env{Row: row})
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestIsOperatorContractAndEOA(t *testing.T) {
+	contractAddr := common.HexToAddress("0x1")
+	eoaAddr := common.HexToAddress("0x2")
+	chain := &fakeChainState{code: map[common.Address][]byte{contractAddr: {0x60}}}
+
+	contractOperand, contractRow := addrOperand(contractAddr)
+	isContract := NewIsOperator(contractOperand, "contract")
+	result, err := isContract.ExecuteBool(context.Background(), &Env{Chain: chain, Row: contractRow})
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	eoaOperand, eoaRow := addrOperand(eoaAddr)
+	isEOA := NewIsOperator(eoaOperand, "eoa")
+	result, err = isEOA.ExecuteBool(context.Background(), &Env{Chain: chain, Row: eoaRow})
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestIsOperatorERC20(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	var code []byte
+	for _, selector := range erc20Selectors {
+		code = append(code, 0x63)
+		code = append(code, selector[:]...)
+	}
+	chain := &fakeChainState{code: map[common.Address][]byte{addr: code}}
+	operand, row := addrOperand(addr)
+	env := &Env{Chain: chain, Row: row}
+
+	op := NewIsOperator(operand, "erc20")
+	result, err := op.ExecuteBool(context.Background(), env)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	op = NewIsOperator(operand, "erc721")
+	result, err = op.ExecuteBool(context.Background(), env)
+	assert.NoError(t, err)
+	assert.False(t, result)
+}
+
+func TestIsOperatorRequiresChainState(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	operand, row := addrOperand(addr)
+	op := NewIsOperator(operand, "contract")
+	_, err := op.ExecuteBool(context.Background(), &Env{Row: row})
+	assert.Error(t, err)
+}
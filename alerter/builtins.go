@@ -0,0 +1,319 @@
+package alerter
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Callable is a function which can be invoked from EMQL through a
+// FunctionCall node. Name is matched case-insensitively (NewFunctionCall
+// lowercases it); ParamTypes and ReturnType let NewFunctionCall validate a
+// call's arity and, where an argument's static type is known, its types
+// ahead of evaluation. Pure marks a Callable whose result depends only on
+// its arguments (not on env, wall-clock time, chain state, ...); a call to
+// a pure Callable with all-constant arguments is folded to its result at
+// parse time instead of being re-evaluated on every row.
+type Callable interface {
+	Name() string
+	Arity() int
+	ParamTypes() []ValueType
+	ReturnType() ValueType
+	Pure() bool
+	Call(ctx context.Context, env *Env, args []Value) (Value, error)
+}
+
+var (
+	builtinsMu sync.RWMutex
+	builtins   = make(map[string]Callable)
+)
+
+// RegisterBuiltin adds c to the global builtin registry under its lower
+// cased Name(), so it can be called from any EMQL query parsed afterwards.
+// It panics if c is nil or a builtin is already registered under the same
+// name, mirroring database/sql.Register: registration happens once, up
+// front (typically from an init function), not as part of normal control
+// flow. This lets third parties embedding this package register their own
+// chain-specific helpers before parsing any rules.
+func RegisterBuiltin(c Callable) {
+	if c == nil {
+		panic("alerter: RegisterBuiltin called with nil Callable")
+	}
+	name := strings.ToLower(c.Name())
+
+	builtinsMu.Lock()
+	defer builtinsMu.Unlock()
+	if _, exists := builtins[name]; exists {
+		panic(fmt.Sprintf("alerter: builtin %q already registered", name))
+	}
+	builtins[name] = c
+}
+
+// LookupBuiltin returns the builtin registered under name, if any. name is
+// matched case-insensitively.
+func LookupBuiltin(name string) (Callable, bool) {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+	c, exists := builtins[strings.ToLower(name)]
+	return c, exists
+}
+
+// genericCallable is a Callable built from plain fields and a closure,
+// letting the starter set below be declared as data rather than one named
+// type per function (the same trick GenericBinaryOperator uses for
+// operators).
+type genericCallable struct {
+	name       string
+	paramTypes []ValueType
+	returnType ValueType
+	pure       bool
+	call       func(ctx context.Context, env *Env, args []Value) (Value, error)
+}
+
+// Name returns the builtin's registered name
+func (c *genericCallable) Name() string {
+	return c.name
+}
+
+// Arity returns the number of arguments the builtin expects
+func (c *genericCallable) Arity() int {
+	return len(c.paramTypes)
+}
+
+// ParamTypes returns the static type expected for each argument
+func (c *genericCallable) ParamTypes() []ValueType {
+	return c.paramTypes
+}
+
+// ReturnType returns the builtin's static result type
+func (c *genericCallable) ReturnType() ValueType {
+	return c.returnType
+}
+
+// Pure returns whether the builtin's result depends only on its arguments
+func (c *genericCallable) Pure() bool {
+	return c.pure
+}
+
+// Call invokes the builtin's underlying function
+func (c *genericCallable) Call(ctx context.Context, env *Env, args []Value) (Value, error) {
+	return c.call(ctx, env, args)
+}
+
+// inferValueType returns the static result type of exp when it is known
+// ahead of evaluation, and false when it can only be determined at
+// runtime (e.g. a FunctionCall to a name outside the builtin registry).
+func inferValueType(exp Expression) (ValueType, bool) {
+	switch e := exp.(type) {
+	case *IntValue:
+		return IntValueType, true
+	case *StringValue:
+		return StringValueType, true
+	case *BoolValue:
+		return BoolValueType, true
+	case *BinaryApplication:
+		return e.Operator.ResultType(), true
+	case *UnaryApplication:
+		return e.Operator.ResultType(), true
+	case *FunctionCall:
+		if builtin, exists := LookupBuiltin(e.FunctionName); exists {
+			return builtin.ReturnType(), true
+		}
+	case *Attribute:
+		return attributeSchema.LeafType(e.Parts)
+	}
+	return 0, false
+}
+
+// validateCall checks a FunctionCall's arguments against builtin's arity
+// and, for arguments whose static type is already known, its declared
+// parameter types.
+func validateCall(builtin Callable, arguments []Expression) error {
+	if len(arguments) != builtin.Arity() {
+		return fmt.Errorf("%s expects %d argument(s), got %d", builtin.Name(), builtin.Arity(), len(arguments))
+	}
+	paramTypes := builtin.ParamTypes()
+	for i, argument := range arguments {
+		actual, known := inferValueType(argument)
+		if !known {
+			continue
+		}
+		if actual != paramTypes[i] {
+			return fmt.Errorf("%s argument %d: expected %v, got %v", builtin.Name(), i+1, paramTypes[i], actual)
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin(&genericCallable{
+		name:       "len",
+		paramTypes: []ValueType{BytesValueType},
+		returnType: IntValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			b, err := valueAsBytes(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewIntValue(big.NewInt(int64(len(b)))), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "keccak256",
+		paramTypes: []ValueType{BytesValueType},
+		returnType: BytesValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			b, err := valueAsBytes(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewBytesValue(crypto.Keccak256(b)), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "slice",
+		paramTypes: []ValueType{BytesValueType, IntValueType, IntValueType},
+		returnType: BytesValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			b, err := valueAsBytes(args[0])
+			if err != nil {
+				return nil, err
+			}
+			start, err := valueAsInt(args[1])
+			if err != nil {
+				return nil, err
+			}
+			end, err := valueAsInt(args[2])
+			if err != nil {
+				return nil, err
+			}
+			if !start.IsInt64() || !end.IsInt64() {
+				return nil, fmt.Errorf("slice bounds out of range")
+			}
+			lo, hi := start.Int64(), end.Int64()
+			if lo < 0 || hi < lo || hi > int64(len(b)) {
+				return nil, fmt.Errorf("slice bounds out of range [%d:%d] with length %d", lo, hi, len(b))
+			}
+			return NewBytesValue(b[lo:hi]), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "hex",
+		paramTypes: []ValueType{IntValueType},
+		returnType: StringValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			n, err := valueAsInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewStringValue(fmt.Sprintf("0x%x", n)), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "addr",
+		paramTypes: []ValueType{StringValueType},
+		returnType: BytesValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			s, err := valueAsString(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewBytesValue(common.HexToAddress(s).Bytes()), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "toint",
+		paramTypes: []ValueType{BytesValueType},
+		returnType: IntValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			b, err := valueAsBytes(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewIntValue(big.NewInt(0).SetBytes(b)), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "now",
+		paramTypes: []ValueType{},
+		returnType: IntValueType,
+		pure:       false,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			return NewIntValue(big.NewInt(time.Now().Unix())), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "abs",
+		paramTypes: []ValueType{IntValueType},
+		returnType: IntValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			n, err := valueAsInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewIntValue(big.NewInt(0).Abs(n)), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "min",
+		paramTypes: []ValueType{IntValueType, IntValueType},
+		returnType: IntValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			a, err := valueAsInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			b, err := valueAsInt(args[1])
+			if err != nil {
+				return nil, err
+			}
+			if a.Cmp(b) <= 0 {
+				return NewIntValue(a), nil
+			}
+			return NewIntValue(b), nil
+		},
+	})
+
+	RegisterBuiltin(&genericCallable{
+		name:       "max",
+		paramTypes: []ValueType{IntValueType, IntValueType},
+		returnType: IntValueType,
+		pure:       true,
+		call: func(ctx context.Context, env *Env, args []Value) (Value, error) {
+			a, err := valueAsInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			b, err := valueAsInt(args[1])
+			if err != nil {
+				return nil, err
+			}
+			if a.Cmp(b) >= 0 {
+				return NewIntValue(a), nil
+			}
+			return NewIntValue(b), nil
+		},
+	})
+}
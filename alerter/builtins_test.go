@@ -0,0 +1,65 @@
+package alerter
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFunctionCallValidatesArity(t *testing.T) {
+	_, err := NewFunctionCall("abs", []Expression{NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(2))})
+	assert.Error(t, err)
+}
+
+func TestNewFunctionCallValidatesParamTypes(t *testing.T) {
+	_, err := NewFunctionCall("abs", []Expression{NewStringValue("not an int")})
+	assert.Error(t, err)
+}
+
+func TestNewFunctionCallAllowsUnregisteredNames(t *testing.T) {
+	// "sum" is an aggregate, not a builtin, and is resolved elsewhere
+	_, err := NewFunctionCall("sum", []Expression{NewIntValue(big.NewInt(1))})
+	assert.NoError(t, err)
+}
+
+func TestNewFunctionCallSkipsCheckForUnknownArgumentType(t *testing.T) {
+	// msg.value is outside every namespace of the static attribute
+	// schema, so its type is unknown and it should be allowed through
+	// regardless of the builtin's declared parameter type
+	_, err := NewFunctionCall("abs", []Expression{NewAttribute([]string{"msg", "value"})})
+	assert.NoError(t, err)
+}
+
+func TestBuiltinsCall(t *testing.T) {
+	env := &Env{}
+	testCases := []struct {
+		name     string
+		args     []Value
+		expected Value
+	}{
+		{"len", []Value{NewBytesValue([]byte{1, 2, 3})}, NewIntValue(big.NewInt(3))},
+		{"toint", []Value{NewBytesValue([]byte{0x2a})}, NewIntValue(big.NewInt(42))},
+		{"hex", []Value{NewIntValue(big.NewInt(255))}, NewStringValue("0xff")},
+		{"abs", []Value{NewIntValue(big.NewInt(-5))}, NewIntValue(big.NewInt(5))},
+		{"min", []Value{NewIntValue(big.NewInt(3)), NewIntValue(big.NewInt(7))}, NewIntValue(big.NewInt(3))},
+		{"max", []Value{NewIntValue(big.NewInt(3)), NewIntValue(big.NewInt(7))}, NewIntValue(big.NewInt(7))},
+		{"slice", []Value{NewBytesValue([]byte{1, 2, 3, 4}), NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(3))},
+			NewBytesValue([]byte{2, 3})},
+	}
+	for _, testCase := range testCases {
+		builtin, exists := LookupBuiltin(testCase.name)
+		assert.True(t, exists, "expected %s to be registered", testCase.name)
+		actual, err := builtin.Call(context.Background(), env, testCase.args)
+		assert.NoError(t, err)
+		assert.True(t, testCase.expected.Equals(actual),
+			"failed with %s%v, expected %v, got %v", testCase.name, testCase.args, testCase.expected, actual)
+	}
+}
+
+func TestSliceOutOfRange(t *testing.T) {
+	builtin, _ := LookupBuiltin("slice")
+	_, err := builtin.Call(context.Background(), &Env{}, []Value{NewBytesValue([]byte{1, 2}), NewIntValue(big.NewInt(0)), NewIntValue(big.NewInt(5))})
+	assert.Error(t, err)
+}
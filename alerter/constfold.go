@@ -0,0 +1,84 @@
+package alerter
+
+import "context"
+
+// foldEnv is the throwaway environment constant folding evaluates against.
+// Folding only ever touches literal operands, and every literal's Eval*/
+// Execute method ignores its env argument entirely, so a single empty Env
+// is safe to reuse for every fold.
+var foldEnv = &Env{}
+
+// isConstantExpr reports whether exp is a literal value which can be
+// evaluated without an environment: the base case constant folding bottoms
+// out on. FunctionCall and BinaryApplication/UnaryApplication nodes are
+// deliberately excluded even though they may themselves be fully foldable,
+// because NewFunctionCall/NewIntBinaryApplication etc. already fold those
+// eagerly at construction time, so by the time a node becomes an operand
+// of another it is already a literal if it can be one at all.
+func isConstantExpr(exp Expression) bool {
+	switch exp.(type) {
+	case *IntValue, *BoolValue, *StringValue:
+		return true
+	}
+	return false
+}
+
+// allConstantExprs reports whether every expression in exps is constant
+func allConstantExprs(exps []Expression) bool {
+	for _, exp := range exps {
+		if !isConstantExpr(exp) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldExpression evaluates exp and returns the result as a literal
+// Expression, or exp unchanged if evaluation fails or the result has no
+// corresponding literal Expression (e.g. a BytesValue, which has no EMQL
+// literal syntax to fold into). Evaluating a constant expression can still
+// panic at runtime (e.g. a literal division by zero), in which case folding
+// backs off and leaves exp to panic lazily when actually executed, exactly
+// as it would have without folding.
+func foldExpression(exp Expression) (result Expression) {
+	defer func() {
+		if recover() != nil {
+			result = exp
+		}
+	}()
+	value, err := exp.Execute(context.Background(), foldEnv)
+	if err != nil {
+		return exp
+	}
+	if literal, ok := value.(Expression); ok {
+		return literal
+	}
+	return exp
+}
+
+// foldPredicate is the Predicate equivalent of foldExpression. Every
+// boolean-valued literal (currently just BoolValue) already implements
+// Predicate, so folding a Predicate never needs to fall back to the
+// original node for lack of a literal representation the way
+// foldExpression can.
+func foldPredicate(pred Predicate) (result Predicate) {
+	defer func() {
+		if recover() != nil {
+			result = pred
+		}
+	}()
+	value, err := pred.Execute(context.Background(), foldEnv)
+	if err != nil {
+		return pred
+	}
+	if literal, ok := value.(Predicate); ok {
+		return literal
+	}
+	return pred
+}
+
+// isBoolLiteral reports whether exp is a literal bool equal to want
+func isBoolLiteral(exp Expression, want bool) bool {
+	b, ok := exp.(*BoolValue)
+	return ok && b.Value == want
+}
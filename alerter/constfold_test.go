@@ -0,0 +1,75 @@
+package alerter
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantFoldingIntBinaryApplication(t *testing.T) {
+	folded := MustNewIntBinaryApplication(NewIntValue(big.NewInt(2)), NewIntValue(big.NewInt(3)), "+")
+	assert.Equal(t, NewIntValue(big.NewInt(5)), folded)
+}
+
+func TestConstantFoldingCompBinaryApplication(t *testing.T) {
+	folded := MustNewCompBinaryApplication(NewIntValue(big.NewInt(2)), NewIntValue(big.NewInt(3)), "<")
+	assert.Equal(t, NewBoolValue(true), folded)
+}
+
+func TestConstantFoldingLeavesNonConstantApplicationAlone(t *testing.T) {
+	msgValue := NewAttribute([]string{"msg", "value"})
+	folded := MustNewIntBinaryApplication(msgValue, NewIntValue(big.NewInt(3)), "+")
+	_, isBinaryApplication := folded.(*BinaryApplication)
+	assert.True(t, isBinaryApplication)
+}
+
+func TestConstantFoldingDoubleNegation(t *testing.T) {
+	msgValue := NewAttribute([]string{"msg", "value"})
+	inner := MustNewCompBinaryApplication(msgValue, NewIntValue(big.NewInt(0)), ">")
+	negated, err := NewPredUnaryApplication(inner, "not")
+	assert.NoError(t, err)
+	doubleNegated, err := NewPredUnaryApplication(negated, "not")
+	assert.NoError(t, err)
+	assert.True(t, inner.Equals(doubleNegated))
+}
+
+func TestConstantFoldingAndTrue(t *testing.T) {
+	msgValue := NewAttribute([]string{"msg", "value"})
+	predicate := MustNewCompBinaryApplication(msgValue, NewIntValue(big.NewInt(0)), ">")
+	assert.True(t, predicate.Equals(NewLogicAndApplication(predicate, NewBoolValue(true))))
+	assert.True(t, predicate.Equals(NewLogicAndApplication(NewBoolValue(true), predicate)))
+}
+
+func TestConstantFoldingOrFalse(t *testing.T) {
+	msgValue := NewAttribute([]string{"msg", "value"})
+	predicate := MustNewCompBinaryApplication(msgValue, NewIntValue(big.NewInt(0)), ">")
+	assert.True(t, predicate.Equals(NewLogicOrApplication(predicate, NewBoolValue(false))))
+	assert.True(t, predicate.Equals(NewLogicOrApplication(NewBoolValue(false), predicate)))
+}
+
+func TestConstantNeedleInIsNotFolded(t *testing.T) {
+	// IN is deliberately never folded (see NewInOperator), so a fully
+	// constant IN predicate still evaluates correctly, it just isn't
+	// reduced to a literal bool at construction time.
+	needle := NewIntValue(big.NewInt(5))
+	haystack := []Expression{NewIntValue(big.NewInt(1)), NewIntValue(big.NewInt(5))}
+	predicate := NewInOperator(needle, haystack)
+	_, isInOperator := predicate.(*InOperator)
+	assert.True(t, isInOperator)
+	matched, err := predicate.ExecuteBool(context.Background(), &Env{})
+	assert.NoError(t, err)
+	assert.True(t, matched)
+}
+
+func TestConstantFoldingPureFunctionCall(t *testing.T) {
+	folded := MustNewFunctionCall("abs", []Expression{NewIntValue(big.NewInt(-5))})
+	assert.Equal(t, NewIntValue(big.NewInt(5)), folded)
+}
+
+func TestConstantFoldingImpureFunctionCallNotFolded(t *testing.T) {
+	called := MustNewFunctionCall("now", []Expression{})
+	_, isFunctionCall := called.(*FunctionCall)
+	assert.True(t, isFunctionCall)
+}
@@ -0,0 +1,151 @@
+package alerter
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// DedupConfig configures SendAlertWithContext's duplicate suppression and
+// per-destination rate limiting, similar to Alertmanager's grouping/
+// inhibit rules.
+type DedupConfig struct {
+	// Window suppresses a repeat of the same (subject, message) pair that
+	// fires again within Window of its last delivery, defaulting to 5
+	// minutes
+	Window time.Duration `yaml:"window"`
+	// MaxPerMinute caps how many alerts a single destination accepts per
+	// fixed one-minute window; further sends within the same window fail with a
+	// rate-limit error instead of being delivered, defaulting to 60
+	MaxPerMinute int `yaml:"max_per_minute"`
+}
+
+// defaultDedupWindow backs DedupConfig.window when Window is unset
+const defaultDedupWindow = 5 * time.Minute
+
+// window returns the dedup suppression window, falling back to
+// defaultDedupWindow when unset
+func (c DedupConfig) window() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultDedupWindow
+}
+
+// defaultMaxPerMinute backs DedupConfig.maxPerMinute when MaxPerMinute is
+// unset
+const defaultMaxPerMinute = 60
+
+// maxPerMinute returns the per-destination rate limit, falling back to
+// defaultMaxPerMinute when unset
+func (c DedupConfig) maxPerMinute() int {
+	if c.MaxPerMinute > 0 {
+		return c.MaxPerMinute
+	}
+	return defaultMaxPerMinute
+}
+
+// dedupCacheSize bounds the fingerprint LRU kept in Alerter.recentAlerts,
+// so a node that fires many distinct alerts over its lifetime doesn't
+// retain every fingerprint it has ever seen
+const dedupCacheSize = 4096
+
+// AlertFingerprint hashes (subject, message) into the stable identifier
+// SendAlertWithContext uses to detect a repeated alert, and that
+// SilenceAlert accepts to mute a known-noisy one
+func AlertFingerprint(subject, message string) string {
+	sum := sha256.Sum256([]byte(subject + "\x00" + message))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprintCache is a small LRU mapping an AlertFingerprint to the Unix
+// timestamp it last fired, backing SendAlertWithContext's dedup check
+type fingerprintCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type fingerprintCacheEntry struct {
+	fingerprint string
+	lastSent    int64
+}
+
+func newFingerprintCache(capacity int) *fingerprintCache {
+	return &fingerprintCache{capacity: capacity, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// allow reports whether fingerprint may fire at now given window, recording
+// now as its new last-sent time whenever it does
+func (c *fingerprintCache) allow(fingerprint string, now int64, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.entries[fingerprint]; exists {
+		entry := elem.Value.(*fingerprintCacheEntry)
+		c.order.MoveToFront(elem)
+		if now-entry.lastSent < int64(window.Seconds()) {
+			return false
+		}
+		entry.lastSent = now
+		return true
+	}
+
+	elem := c.order.PushFront(&fingerprintCacheEntry{fingerprint: fingerprint, lastSent: now})
+	c.entries[fingerprint] = elem
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*fingerprintCacheEntry).fingerprint)
+	}
+	return true
+}
+
+// snapshot returns every entry currently held by c, for persistFingerprints
+func (c *fingerprintCache) snapshot() []FingerprintEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]FingerprintEntry, 0, len(c.entries))
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*fingerprintCacheEntry)
+		entries = append(entries, FingerprintEntry{Fingerprint: e.fingerprint, LastSent: uint64(e.lastSent)})
+	}
+	return entries
+}
+
+// restore seeds c from entries loaded from disk at startup, so a restart
+// doesn't unleash a duplicate flood of whatever was still inside its
+// dedup window when the node went down
+func (c *fingerprintCache) restore(entries []FingerprintEntry) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		c.allow(entries[i].Fingerprint, int64(entries[i].LastSent), 0)
+	}
+}
+
+// rateLimiter is a fixed-window (not sliding) per-minute counter bounding
+// how many alerts a single destination accepts
+type rateLimiter struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+	max         int
+}
+
+// allow reports whether another send is permitted in the current
+// one-minute window, counting it against max when it is
+func (r *rateLimiter) allow(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.windowStart) >= time.Minute {
+		r.windowStart = now
+		r.count = 0
+	}
+	if r.count >= r.max {
+		return false
+	}
+	r.count++
+	return true
+}
@@ -0,0 +1,211 @@
+package alerter
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// EmailTemplateConfig is a named text/html template SMTPSender renders the
+// HTML part of a multipart email from, configured statically on disk
+// under EmailConfig.Templates or registered at runtime (and persisted to
+// ethdb) via Alerter.RegisterEmailTemplate
+type EmailTemplateConfig struct {
+	// HTML is the text/html template source, executed against an
+	// AlertPayload (subject, message, block number, chain id, timestamp,
+	// matched result columns, etc.)
+	HTML string `yaml:"html"`
+}
+
+// EmailTemplate is the on-disk, RLP-encodable representation of a
+// runtime-registered EmailTemplateConfig
+type EmailTemplate struct {
+	Name string
+	HTML string
+}
+
+// RegisterEmailTemplate validates html as a text/html template and makes
+// it available to SMTP destinations as "?template=name" (see
+// NewSMTPSender), persisting it so it survives node restarts. An existing
+// template with the same name is overwritten.
+func (a *Alerter) RegisterEmailTemplate(name string, html string) (*EmailTemplate, error) {
+	if _, err := template.New(name).Parse(html); err != nil {
+		return nil, fmt.Errorf("invalid email template: %v", err)
+	}
+
+	if a.config.Email.Templates == nil {
+		a.config.Email.Templates = make(map[string]EmailTemplateConfig)
+	}
+	a.config.Email.Templates[name] = EmailTemplateConfig{HTML: html}
+
+	templates := a.loadEmailTemplates()
+	replaced := false
+	for i, tmpl := range templates {
+		if tmpl.Name == name {
+			templates[i].HTML = html
+			replaced = true
+			break
+		}
+	}
+	entry := EmailTemplate{Name: name, HTML: html}
+	if !replaced {
+		templates = append(templates, entry)
+	}
+	if err := a.persistEmailTemplates(templates); err != nil {
+		return nil, err
+	}
+	logger.Info("registered email template", "name", name)
+	return &entry, nil
+}
+
+// ListEmailTemplates returns every email template currently available,
+// both statically configured and runtime-registered
+func (a *Alerter) ListEmailTemplates() (templates []EmailTemplate, err error) {
+	for name, cfg := range a.config.Email.Templates {
+		templates = append(templates, EmailTemplate{Name: name, HTML: cfg.HTML})
+	}
+	return templates, nil
+}
+
+// EmailPreview is the dry-run output of Alerter.RenderPreview
+type EmailPreview struct {
+	// HTML is the rendered template output, empty if template was empty;
+	// an unknown template makes RenderPreview return an error instead
+	HTML string
+	// PlainText is HTML auto-derived via htmlToPlainText, or message
+	// unchanged when HTML is empty
+	PlainText string
+}
+
+// RenderPreview renders subject/message through the named email template
+// (see RegisterEmailTemplate) exactly as SendAlert would, without
+// delivering anything, so operators can dry-run a template change
+func (a *Alerter) RenderPreview(subject, message, template string) (*EmailPreview, error) {
+	payload := NewAlertResponse(subject, message, AlertContext{}).Data.(AlertPayload)
+
+	htmlBody, err := renderEmailHTML(&a.config.Email, template, payload)
+	if err != nil {
+		return nil, err
+	}
+	if htmlBody == "" {
+		return &EmailPreview{PlainText: message}, nil
+	}
+	textBody, err := htmlToPlainText(htmlBody)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailPreview{HTML: htmlBody, PlainText: textBody}, nil
+}
+
+// renderEmailHTML renders templateName (looked up in cfg.Templates)
+// against payload, returning "" when templateName is empty and an error
+// when it names a template that doesn't exist
+func renderEmailHTML(cfg *EmailConfig, templateName string, payload AlertPayload) (string, error) {
+	if templateName == "" {
+		return "", nil
+	}
+	templateCfg, ok := cfg.Templates[templateName]
+	if !ok {
+		return "", fmt.Errorf("unknown email template %q", templateName)
+	}
+	tmpl, err := template.New(templateName).Parse(templateCfg.HTML)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// htmlToPlainText walks source's parsed HTML tree and emits a readable
+// plaintext rendering for recipients on plain-text email clients: text
+// nodes verbatim, "<a href>" as "text [url]", headings and paragraphs set
+// off by blank lines, and list items prefixed with "- ".
+func htmlToPlainText(source string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	renderPlainTextNode(&buf, doc)
+	return collapseWhitespace(buf.String()), nil
+}
+
+// renderPlainTextNode appends n's plaintext rendering to buf
+func renderPlainTextNode(buf *bytes.Buffer, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style":
+			return
+		case "br":
+			buf.WriteString("\n")
+			return
+		case "h1", "h2", "h3", "h4", "h5", "h6", "p":
+			buf.WriteString("\n\n")
+			renderPlainTextChildren(buf, n)
+			buf.WriteString("\n\n")
+			return
+		case "li":
+			buf.WriteString("\n- ")
+			renderPlainTextChildren(buf, n)
+			return
+		case "a":
+			var label bytes.Buffer
+			renderPlainTextChildren(&label, n)
+			text := strings.TrimSpace(label.String())
+			if href := htmlAttr(n, "href"); href != "" {
+				fmt.Fprintf(buf, "%s [%s]", text, href)
+			} else {
+				buf.WriteString(text)
+			}
+			return
+		}
+	}
+	renderPlainTextChildren(buf, n)
+}
+
+// renderPlainTextChildren renders every child of n in document order
+func renderPlainTextChildren(buf *bytes.Buffer, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderPlainTextNode(buf, c)
+	}
+}
+
+// htmlAttr returns n's attribute named key, or "" if it has none
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// whitespaceRunRE collapses runs of horizontal whitespace within a line
+var whitespaceRunRE = regexp.MustCompile(`[ \t]+`)
+
+// blankLineRunRE collapses more than one consecutive blank line, left
+// behind by adjacent block-level elements (e.g. a heading followed by a
+// paragraph), down to a single one
+var blankLineRunRE = regexp.MustCompile(`\n{3,}`)
+
+// collapseWhitespace normalizes the raw text accumulated by
+// renderPlainTextNode into a readable plaintext body
+func collapseWhitespace(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(whitespaceRunRE.ReplaceAllString(line, " "))
+	}
+	collapsed := blankLineRunRE.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(collapsed)
+}
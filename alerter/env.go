@@ -1,6 +1,14 @@
 package alerter
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
 
 // ContractMetrics is a set of metrics collected about a single contract
 type ContractMetrics struct {
@@ -8,20 +16,119 @@ type ContractMetrics struct {
 
 // BuiltinFunction is any function which is built-in
 // and can be called from EMQL expressions
-type BuiltinFunction func(*Env, ...Value) (Value, error)
+type BuiltinFunction func(context.Context, *Env, ...Value) (Value, error)
+
+// ChainState is the on-chain state access needed to resolve contract.*
+// attributes and IsOperator's CONTRACT/EOA/ERC20/ERC721 targets. It is
+// satisfied by *ethclient.Client.
+type ChainState interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// LogSource installs a historical and/or live log filter on behalf of a
+// streaming EMQL subscription (see RunLiveQuery). It is satisfied by
+// *ethclient.Client.
+type LogSource interface {
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
 
 // Env contains all the current environment held by the node
 type Env struct {
 	Metrics   map[string]ContractMetrics
 	Functions map[string]BuiltinFunction
+	// Row is the event (log/transaction) being evaluated against, if any,
+	// as used when a streaming query evaluates a WHERE predicate
+	Row Row
+	// Resolver resolves tx.*/block.*/log.*/contract.* Attributes not
+	// already present in Row
+	Resolver *AttributeResolver
+	// Chain and BlockNumber back IsOperator's CONTRACT/EOA/ERC20/ERC721
+	// targets, which need to read arbitrary addresses' code rather than
+	// just those covered by Resolver's contract provider
+	Chain       ChainState
+	BlockNumber *big.Int
+	// Aggregates overrides the global aggregate registry for a single
+	// query, the same way Functions overrides the scalar builtin registry
+	Aggregates map[string]AggregateFunction
+	// Windows holds one WindowExecutor per distinct WindowFunctionCall
+	// evaluated against this Env, keyed by the call's String(). Lazily
+	// populated by windowExecutorFor as a streaming query evaluates each
+	// row against its Selected expressions.
+	Windows map[string]*WindowExecutor
+	// preparedCache backs Prepare, an LRU cache of PreparedStatements
+	// keyed by raw query string. Lazily initialized on first use.
+	preparedCache *preparedStatementCache
+}
+
+// windowExecutorFor returns e's WindowExecutor for call, creating both the
+// Windows map and the executor itself on first use.
+func (e *Env) windowExecutorFor(call *WindowFunctionCall) *WindowExecutor {
+	if e.Windows == nil {
+		e.Windows = make(map[string]*WindowExecutor)
+	}
+	key := call.String()
+	executor, exists := e.Windows[key]
+	if !exists {
+		executor = NewWindowExecutor(call)
+		e.Windows[key] = executor
+	}
+	return executor
 }
 
 // ExecuteFunction retrieves the function from the environment
 // and executes it
-func (e *Env) ExecuteFunction(name string, args ...Value) (Value, error) {
+func (e *Env) ExecuteFunction(ctx context.Context, name string, args ...Value) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	function, exists := e.Functions[name]
 	if !exists {
 		return nil, fmt.Errorf("unkonwn function %s", name)
 	}
-	return function(e, args...)
+	return function(ctx, e, args...)
+}
+
+// Row is a single matched event (e.g. a log or transaction), keyed by
+// attribute name, as produced while iterating one of a FROM clause's
+// sources
+type Row map[string]Value
+
+// JoinRow pairs a row from the left side of a JoinClause with the
+// matching row from its right side
+type JoinRow struct {
+	Left  Row
+	Right Row
+}
+
+// KeyFunc extracts the join key used to correlate rows on one side of a
+// JoinClause's ON predicate
+type KeyFunc func(Row) (Value, error)
+
+// HashJoin performs a simple equi-join between left and right, building a
+// hash table over right keyed by rightKey and probing it once per left
+// row with leftKey. Nothing in this package calls it: query evaluation
+// does not evaluate a JoinClause's ON predicate.
+func HashJoin(left []Row, right []Row, leftKey KeyFunc, rightKey KeyFunc) ([]JoinRow, error) {
+	buckets := make(map[interface{}][]Row)
+	for _, row := range right {
+		key, err := rightKey(row)
+		if err != nil {
+			return nil, err
+		}
+		buckets[key.Raw()] = append(buckets[key.Raw()], row)
+	}
+
+	var joined []JoinRow
+	for _, row := range left {
+		key, err := leftKey(row)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range buckets[key.Raw()] {
+			joined = append(joined, JoinRow{Left: row, Right: match})
+		}
+	}
+	return joined, nil
 }
@@ -0,0 +1,165 @@
+package alerter
+
+import (
+	"context"
+	"fmt"
+)
+
+// GroupByExecutor partitions an unbounded stream of Rows by a
+// SelectStatement's GROUP BY key, accumulating each group's aggregate
+// Selected expressions (COUNT, SUM, AVG, MIN, MAX, DISTINCT) as rows
+// arrive rather than buffering them, then projects one Row per group
+// once the stream ends. A single Selected expression must currently be
+// either exactly one of GroupBy's Attributes or a single-argument call
+// to a registered aggregate; expressions mixing the two (e.g.
+// "sum(x) / count(x)") are rejected by NewGroupByExecutor.
+type GroupByExecutor struct {
+	stmt         *SelectStatement
+	baseEnv      Env
+	keyAttrs     []*Attribute
+	aggregateIdx []int
+	groups       map[string]*groupAccumulator
+	order        []string
+}
+
+// groupAccumulator is the running state for a single GROUP BY key: the
+// key's own Values, re-emitted in the output row, plus one Aggregator per
+// aggregate Selected expression, indexed the same way in both
+// SelectStatement.Selected and groupAccumulator.aggregators
+type groupAccumulator struct {
+	keyValues   []Value
+	aggregators map[int]Aggregator
+}
+
+// NewGroupByExecutor validates stmt's GROUP BY/Selected and returns an
+// executor ready to accept rows through Add. env.Aggregates, if set,
+// overrides the global aggregate registry for this query the same way
+// env.Functions overrides scalar builtins.
+func NewGroupByExecutor(stmt *SelectStatement, env *Env) (*GroupByExecutor, error) {
+	if stmt.GroupBy == nil {
+		return nil, fmt.Errorf("SELECT statement has no GROUP BY clause")
+	}
+	aggregateIdx, err := validateGroupBySelect(stmt, env)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupByExecutor{
+		stmt:         stmt,
+		baseEnv:      *env,
+		keyAttrs:     stmt.GroupBy.Attributes,
+		aggregateIdx: aggregateIdx,
+		groups:       make(map[string]*groupAccumulator),
+	}, nil
+}
+
+// validateGroupBySelect checks that every Selected expression is either
+// one of GroupBy's Attributes or a call to a registered aggregate taking
+// exactly one argument, returning the indices of the aggregate calls.
+func validateGroupBySelect(stmt *SelectStatement, env *Env) ([]int, error) {
+	var aggregateIdx []int
+	for i, expression := range stmt.Selected {
+		if call, ok := expression.(*FunctionCall); ok {
+			if _, exists := lookupAggregate(env, call.FunctionName); exists {
+				if len(call.Arguments) != 1 {
+					return nil, fmt.Errorf("aggregate %s expects exactly 1 argument, got %d", call.FunctionName, len(call.Arguments))
+				}
+				aggregateIdx = append(aggregateIdx, i)
+				continue
+			}
+		}
+		if !attributeInGroupBy(expression, stmt.GroupBy.Attributes) {
+			return nil, fmt.Errorf("selected expression %s is neither an aggregate nor in GROUP BY", expression)
+		}
+	}
+	return aggregateIdx, nil
+}
+
+// attributeInGroupBy reports whether expression is one of groupBy's
+// Attributes
+func attributeInGroupBy(expression Expression, groupBy []*Attribute) bool {
+	attribute, ok := expression.(*Attribute)
+	if !ok {
+		return false
+	}
+	for _, candidate := range groupBy {
+		if attribute.Equals(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add partitions row into its group, creating the group's Aggregators on
+// first sight of its key, then folds row into every aggregate Selected
+// expression. ctx is checked before each row is processed so a long-lived
+// stream of Add calls can be aborted between rows.
+func (e *GroupByExecutor) Add(ctx context.Context, row Row) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.baseEnv.Row = row
+	env := &e.baseEnv
+
+	keyValues := make([]Value, len(e.keyAttrs))
+	for i, attribute := range e.keyAttrs {
+		value, err := attribute.Execute(ctx, env)
+		if err != nil {
+			return err
+		}
+		keyValues[i] = value
+	}
+	key := serializeValues(keyValues)
+
+	group, exists := e.groups[key]
+	if !exists {
+		group = &groupAccumulator{keyValues: keyValues, aggregators: make(map[int]Aggregator)}
+		for _, idx := range e.aggregateIdx {
+			call := e.stmt.Selected[idx].(*FunctionCall)
+			aggregate, _ := lookupAggregate(env, call.FunctionName)
+			group.aggregators[idx] = aggregate.New()
+		}
+		e.groups[key] = group
+		e.order = append(e.order, key)
+	}
+
+	for _, idx := range e.aggregateIdx {
+		call := e.stmt.Selected[idx].(*FunctionCall)
+		value, err := call.Arguments[0].Execute(ctx, env)
+		if err != nil {
+			return err
+		}
+		if err := group.aggregators[idx].Add(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Results finalizes every group seen so far into one projected Row each,
+// in the order groups were first encountered, applying GroupBy's
+// Attributes, the Selected projection and any Aliases
+func (e *GroupByExecutor) Results() ([]Row, error) {
+	rows := make([]Row, 0, len(e.order))
+	for _, key := range e.order {
+		group := e.groups[key]
+		row := make(Row)
+		for i, attribute := range e.keyAttrs {
+			row[attribute.String()] = group.keyValues[i]
+		}
+		for i, expression := range e.stmt.Selected {
+			if aggregator, ok := group.aggregators[i]; ok {
+				value, err := aggregator.Result()
+				if err != nil {
+					return nil, err
+				}
+				row[expression.String()] = value
+			}
+		}
+		for alias, expression := range e.stmt.Aliases {
+			row[alias] = row[expression.String()]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
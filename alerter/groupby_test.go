@@ -0,0 +1,84 @@
+package alerter
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rowWithValue(sender string, value int64) Row {
+	return Row{
+		"msg.sender": NewStringValue(sender),
+		"msg.value":  NewIntValue(big.NewInt(value)),
+	}
+}
+
+func TestGroupByExecutorCountAndSum(t *testing.T) {
+	sender := NewAttribute([]string{"msg", "sender"})
+	value := NewAttribute([]string{"msg", "value"})
+	stmt := &SelectStatement{
+		Selected: []Expression{
+			sender,
+			MustNewFunctionCall("count", []Expression{value}),
+			MustNewFunctionCall("sum", []Expression{value}),
+		},
+		GroupBy: &GroupByClause{Attributes: []*Attribute{sender}},
+	}
+
+	executor, err := NewGroupByExecutor(stmt, &Env{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, executor.Add(context.Background(), rowWithValue("alice", 10)))
+	assert.NoError(t, executor.Add(context.Background(), rowWithValue("alice", 20)))
+	assert.NoError(t, executor.Add(context.Background(), rowWithValue("bob", 5)))
+
+	rows, err := executor.Results()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	assert.Equal(t, NewStringValue("alice"), rows[0]["msg.sender"])
+	assert.Equal(t, NewIntValue(big.NewInt(2)), rows[0][MustNewFunctionCall("count", []Expression{value}).(*FunctionCall).String()])
+	assert.Equal(t, NewIntValue(big.NewInt(30)), rows[0][MustNewFunctionCall("sum", []Expression{value}).(*FunctionCall).String()])
+
+	assert.Equal(t, NewStringValue("bob"), rows[1]["msg.sender"])
+	assert.Equal(t, NewIntValue(big.NewInt(1)), rows[1][MustNewFunctionCall("count", []Expression{value}).(*FunctionCall).String()])
+}
+
+func TestGroupByExecutorAppliesAliases(t *testing.T) {
+	sender := NewAttribute([]string{"msg", "sender"})
+	value := NewAttribute([]string{"msg", "value"})
+	total := MustNewFunctionCall("sum", []Expression{value})
+	stmt := &SelectStatement{
+		Selected: []Expression{sender, total},
+		GroupBy:  &GroupByClause{Attributes: []*Attribute{sender}},
+		Aliases:  map[string]Expression{"total": total},
+	}
+
+	executor, err := NewGroupByExecutor(stmt, &Env{})
+	assert.NoError(t, err)
+	assert.NoError(t, executor.Add(context.Background(), rowWithValue("alice", 7)))
+
+	rows, err := executor.Results()
+	assert.NoError(t, err)
+	assert.Equal(t, NewIntValue(big.NewInt(7)), rows[0]["total"])
+}
+
+func TestGroupByExecutorRejectsUngroupedAttribute(t *testing.T) {
+	sender := NewAttribute([]string{"msg", "sender"})
+	value := NewAttribute([]string{"msg", "value"})
+	stmt := &SelectStatement{
+		Selected: []Expression{sender, value},
+		GroupBy:  &GroupByClause{Attributes: []*Attribute{sender}},
+	}
+
+	_, err := NewGroupByExecutor(stmt, &Env{})
+	assert.Error(t, err)
+}
+
+func TestGroupByExecutorRequiresGroupByClause(t *testing.T) {
+	stmt := &SelectStatement{Selected: []Expression{NewAttribute([]string{"msg", "sender"})}}
+	_, err := NewGroupByExecutor(stmt, &Env{})
+	assert.Error(t, err)
+}
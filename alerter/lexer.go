@@ -16,6 +16,7 @@ var caseInsensitiveTokens = map[string]bool{
 	"where":        true,
 	"since":        true,
 	"until":        true,
+	"limit":        true,
 	"group by":     true,
 	"null":         true,
 	"contract":     true,
@@ -26,6 +27,39 @@ var caseInsensitiveTokens = map[string]bool{
 	"transactions": true,
 	"is":           true,
 	"in":           true,
+	"interval":     true,
+	"now":          true,
+	"ago":          true,
+	"day":          true,
+	"days":         true,
+	"hour":         true,
+	"hours":        true,
+	"minute":       true,
+	"minutes":      true,
+	"join":         true,
+	"on":           true,
+	"having":       true,
+	"asc":          true,
+	"desc":         true,
+	"over":         true,
+	"rows":         true,
+	"between":      true,
+	"preceding":    true,
+	"current":      true,
+	"row":          true,
+	"with":         true,
+	"create":       true,
+	"view":         true,
+}
+
+// twoWordKeywords maps a first keyword to the second word which combines
+// with it into a single token, e.g. "group"+"by" -> "group by". Matching
+// is literal (not case-folded) for the same reason "group by" already
+// was: the component words aren't themselves in caseInsensitiveTokens.
+var twoWordKeywords = map[string]string{
+	"group":     "by",
+	"order":     "by",
+	"partition": "by",
 }
 
 // Lexer represents a lexer for a single query
@@ -97,11 +131,31 @@ func (l *Lexer) readString() error {
 	return nil
 }
 
+// readParam reads a bind parameter token, e.g. "?since", as a single
+// token so the parser can recognize it wherever a literal is otherwise
+// expected (see Parser.eatParam)
+func (l *Lexer) readParam() error {
+	l.advance()
+	buffer := bytes.NewBufferString("?")
+	for !l.IsDone() && (unicode.IsLetter(l.peek()) || unicode.IsDigit(l.peek()) || l.peek() == '_') {
+		buffer.WriteRune(l.peek())
+		l.advance()
+	}
+	l.currentToken = buffer.String()
+	return nil
+}
+
 func (l *Lexer) readSymbol(c rune) {
 	var token string
 	if c == '<' && l.peek() == '>' {
 		token = "<>"
 		l.advance()
+	} else if c == '<' && l.peek() == '<' {
+		token = "<<"
+		l.advance()
+	} else if c == '>' && l.peek() == '>' {
+		token = ">>"
+		l.advance()
 	} else if (c == '>' || c == '<') && l.peek() == '=' {
 		token = string([]rune{c, l.peek()})
 		l.advance()
@@ -132,6 +186,10 @@ func (l *Lexer) readToken() error {
 		return l.readString()
 	}
 
+	if c == '?' {
+		return l.readParam()
+	}
+
 	if !(unicode.IsLetter(c) || unicode.IsDigit(c)) {
 		l.advance()
 		if c == '-' && l.peek() == '-' {
@@ -172,9 +230,10 @@ func (l *Lexer) NextToken() (string, bool, error) {
 		return "", false, err
 	}
 
-	// special case to treat group by as a single token
-	if token == "group" && l.currentToken == "by" {
-		token = "group by"
+	// fold two-word keywords (group by, order by, partition by) into a
+	// single token so the parser can match them with a plain peek()
+	if second, ok := twoWordKeywords[token]; ok && l.currentToken == second {
+		token = token + " " + second
 		err = l.readToken()
 	}
 
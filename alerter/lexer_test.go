@@ -30,3 +30,14 @@ func TestNextToken(t *testing.T) {
 	assert.Nil(t, err)
 	assert.False(t, hasNext)
 }
+
+func TestNextTokenParam(t *testing.T) {
+	lexer := NewLexer(`FROM ?address SINCE ?since LIMIT ?limit`)
+	expectedTokens := []string{"from", "?address", "since", "?since", "limit", "?limit"}
+	for _, expected := range expectedTokens {
+		nextToken, hasNext, err := lexer.NextToken()
+		assert.True(t, hasNext)
+		assert.Equal(t, expected, nextToken)
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,22 @@
+package alerter
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// alertsSentCounter returns the alerts_sent_total counter for a
+// (transport, status) pair, registering it on first use. go-ethereum's
+// metrics package has no notion of Prometheus-style labels, so the pair is
+// folded into a hierarchical metric name instead, e.g.
+// "alerter/alerts_sent_total/http/ok".
+func alertsSentCounter(transport, status string) metrics.Counter {
+	return metrics.GetOrRegisterCounter(fmt.Sprintf("alerter/alerts_sent_total/%s/%s", transport, status), nil)
+}
+
+// alertsSendDurationTimer returns the alerts_send_duration_seconds timer
+// for a destination transport, registering it on first use
+func alertsSendDurationTimer(transport string) metrics.Timer {
+	return metrics.GetOrRegisterTimer(fmt.Sprintf("alerter/alerts_send_duration_seconds/%s", transport), nil)
+}
@@ -0,0 +1,345 @@
+package alerter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"github.com/streadway/amqp"
+)
+
+// mqEndpoint splits a message-queue destination's endpoint (the part
+// after registerSender strips the "nats:"/"kafka:"/"amqp:" transport
+// prefix, e.g. "//localhost:4222/alerts.mycontract") into the broker URL
+// and the subject/topic/queue name carried in its path. Only a single
+// broker host is supported; a comma-separated list isn't parsed.
+func mqEndpoint(scheme string, endpoint string) (brokerURL string, name string, err error) {
+	parsed, err := url.Parse(scheme + ":" + endpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s destination %s: %v", scheme, endpoint, err)
+	}
+	name = strings.TrimPrefix(parsed.Path, "/")
+	parsed.Path = ""
+	return parsed.String(), name, nil
+}
+
+// queueKeyTemplate looks up and parses the KeyTemplate configured for a
+// message-queue destination's endpoint in cfg.Notifications.Queues,
+// returning nil when none is configured or it fails to parse.
+func queueKeyTemplate(cfg *Config, endpoint string) *template.Template {
+	queueCfg, ok := cfg.Notifications.Queues[endpoint]
+	if !ok || queueCfg.KeyTemplate == "" {
+		return nil
+	}
+	tmpl, err := template.New(endpoint).Parse(queueCfg.KeyTemplate)
+	if err != nil {
+		logger.Warn("invalid queue key template", "endpoint", endpoint, "err", err)
+		return nil
+	}
+	return tmpl
+}
+
+// renderQueueKey renders tmpl against payload, returning the empty string
+// when tmpl is nil (no KeyTemplate configured for this destination)
+func renderQueueKey(tmpl *template.Template, payload AlertPayload) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// NATSSender publishes alerts as JSON to a NATS subject, turning the
+// alerter into a building block for broader event-driven pipelines
+// rather than a terminal notifier, mirroring the logworker-over-NATS
+// pattern used elsewhere. Delivery is at-least-once: Publish is followed
+// by a Flush round-trip to the server, so a failed publish surfaces as an
+// error withRetry retries instead of being silently dropped.
+type NATSSender struct {
+	mu      sync.Mutex
+	url     string
+	subject string
+	conn    *nats.Conn
+	keyTmpl *template.Template
+	retry   retryConfig
+}
+
+// NewNATSSender returns a new sender for endpoint, e.g.
+// "//localhost:4222/alerts.mycontract" once the "nats:" transport prefix
+// has been stripped by registerSender. The connection is established
+// lazily on the first Send.
+func NewNATSSender(endpoint string, cfg *Config) Sender {
+	brokerURL, subject, err := mqEndpoint("nats", endpoint)
+	if err != nil {
+		logger.Warn("invalid NATS destination", "endpoint", endpoint, "err", err)
+	}
+	return &NATSSender{
+		url:     brokerURL,
+		subject: subject,
+		keyTmpl: queueKeyTemplate(cfg, endpoint),
+		retry:   cfg.Notifications.retryConfig(),
+	}
+}
+
+// connection returns s's NATS connection, dialing it on first use or
+// after a previous connection was closed
+func (s *NATSSender) connection() (*nats.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil && !s.conn.IsClosed() {
+		return s.conn, nil
+	}
+	conn, err := nats.Connect(s.url)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// Send publishes subject/message with no structured context
+func (s *NATSSender) Send(ctx context.Context, subject string, message string) error {
+	return s.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured publishes the JSON-encoded AlertPayload to s.subject,
+// suffixed with the rendered key template, if any, so downstream
+// consumers can partition work (e.g. by the matched contract address).
+// NATS core pub/sub has no context-aware publish call, so ctx is only
+// checked before dialing/publishing, not during the round trip itself.
+func (s *NATSSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	payload := NewAlertResponse(subject, message, alertCtx).Data.(AlertPayload)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	key, err := renderQueueKey(s.keyTmpl, payload)
+	if err != nil {
+		return err
+	}
+	fullSubject := s.subject
+	if key != "" {
+		fullSubject = fullSubject + "." + key
+	}
+	return withRetry(s.retry, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		conn, err := s.connection()
+		if err != nil {
+			return err
+		}
+		if err := conn.Publish(fullSubject, body); err != nil {
+			return err
+		}
+		return conn.FlushTimeout(s.retry.MaxDelay)
+	})
+}
+
+// Close disconnects from the NATS server. Implements Closer.
+func (s *NATSSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return nil
+}
+
+// KafkaSender publishes alerts as JSON to a Kafka topic, keyed by an
+// optional per-destination key template (e.g. the matched contract
+// address) so downstream consumers can shard work. RequiredAcks is set
+// to kafka.RequireAll, so a write is only considered successful once
+// every in-sync replica has it, giving at-least-once delivery together
+// with withRetry.
+type KafkaSender struct {
+	writer  *kafka.Writer
+	keyTmpl *template.Template
+	retry   retryConfig
+}
+
+// NewKafkaSender returns a new sender for endpoint, e.g.
+// "//localhost:9092/alerts" once the "kafka:" transport prefix has been
+// stripped by registerSender
+func NewKafkaSender(endpoint string, cfg *Config) Sender {
+	brokerURL, topic, err := mqEndpoint("kafka", endpoint)
+	if err != nil {
+		logger.Warn("invalid Kafka destination", "endpoint", endpoint, "err", err)
+	}
+	broker := strings.TrimPrefix(brokerURL, "kafka://")
+	return &KafkaSender{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(broker),
+			Topic:        topic,
+			Balancer:     &kafka.LeastBytes{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		keyTmpl: queueKeyTemplate(cfg, endpoint),
+		retry:   cfg.Notifications.retryConfig(),
+	}
+}
+
+// Send publishes subject/message with no structured context
+func (s *KafkaSender) Send(ctx context.Context, subject string, message string) error {
+	return s.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured publishes the JSON-encoded AlertPayload, keyed by the
+// rendered key template, if any. ctx is passed straight through to
+// WriteMessages, so it cancels the write the same way it would any other
+// network call.
+func (s *KafkaSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	payload := NewAlertResponse(subject, message, alertCtx).Data.(AlertPayload)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	key, err := renderQueueKey(s.keyTmpl, payload)
+	if err != nil {
+		return err
+	}
+	return withRetry(s.retry, func() error {
+		return s.writer.WriteMessages(ctx, kafka.Message{
+			Key:   []byte(key),
+			Value: body,
+		})
+	})
+}
+
+// Close flushes and closes the underlying Kafka writer. Implements Closer.
+func (s *KafkaSender) Close() error {
+	return s.writer.Close()
+}
+
+// AMQPSender publishes alerts as JSON to a durable AMQP (RabbitMQ) queue.
+// The channel is put into confirm mode and every publish waits for the
+// broker's ack before returning, giving at-least-once delivery the same
+// way NATSSender's Flush does.
+type AMQPSender struct {
+	mu       sync.Mutex
+	url      string
+	queue    string
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	chClosed bool
+	confirms chan amqp.Confirmation
+	retry    retryConfig
+}
+
+// NewAMQPSender returns a new sender for endpoint, e.g.
+// "//guest:guest@localhost:5672/alerts" once the "amqp:" transport
+// prefix has been stripped by registerSender
+func NewAMQPSender(endpoint string, cfg *Config) Sender {
+	brokerURL, queue, err := mqEndpoint("amqp", endpoint)
+	if err != nil {
+		logger.Warn("invalid AMQP destination", "endpoint", endpoint, "err", err)
+	}
+	return &AMQPSender{
+		url:   brokerURL,
+		queue: queue,
+		retry: cfg.Notifications.retryConfig(),
+	}
+}
+
+// channel lazily dials the broker, opens a confirm-mode channel and
+// declares s.queue as durable, reusing both across sends until Close or
+// the connection drops
+func (s *AMQPSender) channel() (*amqp.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil && !s.chClosed {
+		return s.ch, nil
+	}
+	conn, err := amqp.Dial(s.url)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := ch.QueueDeclare(s.queue, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	s.ch = ch
+	s.chClosed = false
+	s.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	// *amqp.Channel has no IsClosed method (unlike *amqp.Connection, used
+	// the same way above for conn), so track liveness ourselves via the
+	// channel's own close notification.
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		<-closed
+		s.mu.Lock()
+		s.chClosed = true
+		s.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// Send publishes subject/message with no structured context
+func (s *AMQPSender) Send(ctx context.Context, subject string, message string) error {
+	return s.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured publishes the JSON-encoded AlertPayload to s.queue,
+// blocking until the broker acks the message. streadway/amqp predates
+// context support, so ctx is only checked before each attempt, not while
+// waiting on the broker's confirmation.
+func (s *AMQPSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	payload := NewAlertResponse(subject, message, alertCtx).Data.(AlertPayload)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return withRetry(s.retry, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ch, err := s.channel()
+		if err != nil {
+			return err
+		}
+		if err := ch.Publish("", s.queue, false, false, amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		}); err != nil {
+			return err
+		}
+		if confirm := <-s.confirms; !confirm.Ack {
+			return fmt.Errorf("broker did not ack message to queue %s", s.queue)
+		}
+		return nil
+	})
+}
+
+// Close disconnects from the AMQP broker. Implements Closer.
+func (s *AMQPSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -20,6 +21,11 @@ type Parser struct {
 	Lexer   *Lexer
 	buffer  []string
 	hasNext bool
+	// ctes holds the CTE/view names currently visible while parsing a
+	// FROM clause, set by parseWith for the duration of the enclosing
+	// ParseSelect call (including any subqueries it parses) and restored
+	// to the enclosing scope's value afterwards.
+	ctes map[string]*SelectStatement
 }
 
 // IsValidIdentifier returns true if value is a valid identifier:
@@ -54,12 +60,120 @@ func NewParser(lexer *Lexer) (*Parser, error) {
 	return parser, parser.advance()
 }
 
-// ParseSelect parses an EMQL select statement
-func (p *Parser) ParseSelect() (*SelectStatement, error) {
+// ParseStatement parses any top-level EMQL statement: a WITH-prefixed or
+// bare SELECT, or a CREATE VIEW definition. New callers should use this
+// entry point; ParseSelect remains for callers that only ever expect a
+// plain SELECT (e.g. RegisterQuery, Subscribe).
+func (p *Parser) ParseStatement() (Statement, error) {
+	if p.peek() == "create" {
+		return p.parseCreateView()
+	}
+	return p.ParseSelect()
+}
+
+// parseCreateView parses a top-level "CREATE VIEW name AS select ..."
+// statement
+func (p *Parser) parseCreateView() (*CreateViewStatement, error) {
+	if err := p.eat("create"); err != nil {
+		return nil, err
+	}
+	if err := p.eat("view"); err != nil {
+		return nil, err
+	}
+	name, err := p.eatIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.eat("as"); err != nil {
+		return nil, err
+	}
+	query, err := p.ParseSelect()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateViewStatement{Name: name, Query: query}, nil
+}
+
+// parseWith parses a "WITH name AS (select ...), name2 AS (...)" prefix,
+// registering each definition into p.ctes as soon as it is parsed so a
+// later CTE in the same clause may reference an earlier one by name.
+// p.ctes is restored to its prior value by the caller (ParseSelect) once
+// the whole statement, including any subqueries, has been parsed.
+func (p *Parser) parseWith() ([]CTEDefinition, error) {
+	if err := p.eat("with"); err != nil {
+		return nil, err
+	}
+	ctes := make(map[string]*SelectStatement, len(p.ctes))
+	for name, query := range p.ctes {
+		ctes[name] = query
+	}
+	p.ctes = ctes
+
+	var defs []CTEDefinition
+	for {
+		def, err := p.parseCTEDefinition()
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+		ctes[def.Name] = def.Query
+		if p.peek() != "," {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return defs, nil
+}
+
+// parseCTEDefinition parses a single "name AS (select ...)" binding
+func (p *Parser) parseCTEDefinition() (CTEDefinition, error) {
+	name, err := p.eatIdentifier()
+	if err != nil {
+		return CTEDefinition{}, err
+	}
+	if err := p.eat("as"); err != nil {
+		return CTEDefinition{}, err
+	}
+	if err := p.eat("("); err != nil {
+		return CTEDefinition{}, err
+	}
+	query, err := p.ParseSelect()
+	if err != nil {
+		return CTEDefinition{}, err
+	}
+	if err := p.eat(")"); err != nil {
+		return CTEDefinition{}, err
+	}
+	return CTEDefinition{Name: name, Query: query}, nil
+}
+
+// ParseSelect parses an EMQL select statement, optionally prefixed by a
+// WITH clause
+func (p *Parser) ParseSelect() (stmt *SelectStatement, err error) {
+	logger.Debug("parsing EMQL statement")
+	defer func() {
+		if err != nil {
+			logger.Debug("EMQL parse failed", "err", err)
+		}
+	}()
+
+	previousCTEs := p.ctes
+	defer func() { p.ctes = previousCTEs }()
+
+	var with []CTEDefinition
+	if p.peek() == "with" {
+		if with, err = p.parseWith(); err != nil {
+			return nil, err
+		}
+	}
+
 	var predicate Predicate = nil
 	var since *BlockRef = nil
 	var until *BlockRef = nil
 	var limit *int64 = nil
+	var limitParam string
 	var offset *int64 = nil
 	var groupBy *GroupByClause = nil
 
@@ -112,11 +226,17 @@ func (p *Parser) ParseSelect() (*SelectStatement, error) {
 		if err = p.advance(); err != nil {
 			return nil, err
 		}
-		limitValue, err := p.eatIntLiteral()
-		if err != nil {
-			return nil, err
+		if strings.HasPrefix(p.peek(), "?") {
+			if limitParam, err = p.eatParam(); err != nil {
+				return nil, err
+			}
+		} else {
+			limitValue, err := p.eatIntLiteral()
+			if err != nil {
+				return nil, err
+			}
+			limit = &limitValue
 		}
-		limit = &limitValue
 	}
 
 	token = p.peek()
@@ -141,25 +261,201 @@ func (p *Parser) ParseSelect() (*SelectStatement, error) {
 		}
 	}
 
+	var having Predicate = nil
+	token = p.peek()
+	if token == "having" {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		if having, err = p.parseOrCondition(); err != nil {
+			return nil, err
+		}
+	}
+
+	var orderBy []OrderByElem
+	token = p.peek()
+	if token == "order by" {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		if orderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &SelectStatement{
-		Selected: selected,
-		From:     fromClause,
-		Where:    predicate,
-		Since:    since,
-		Until:    until,
-		Limit:    limit,
-		Offset:   offset,
-		GroupBy:  groupBy,
-		Aliases:  aliases,
+		Selected:   selected,
+		From:       fromClause,
+		Where:      predicate,
+		Since:      since,
+		Until:      until,
+		Limit:      limit,
+		LimitParam: limitParam,
+		Offset:     offset,
+		GroupBy:    groupBy,
+		Having:     having,
+		OrderBy:    orderBy,
+		Aliases:    aliases,
+		With:       with,
 	}, nil
 }
 
+// parseFrom parses a FROM clause, including any comma-separated or JOINed
+// sources: FROM 0xA a, 0xB b JOIN 0xC c ON a.tx.hash = c.tx.hash
 func (p *Parser) parseFrom() (*FromClause, error) {
-	from, err := p.parseHex()
+	source, err := p.parseFromSource()
 	if err != nil {
 		return nil, err
 	}
-	return &FromClause{Address: from}, nil
+	from := &FromClause{Address: source.Address, Sources: []FromSource{source}}
+
+	for {
+		token := p.peek()
+		if token == "," {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			next, err := p.parseFromSource()
+			if err != nil {
+				return nil, err
+			}
+			from.Sources = append(from.Sources, next)
+			continue
+		}
+
+		if token == "join" {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			next, err := p.parseFromSource()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.eat("on"); err != nil {
+				return nil, err
+			}
+			onPredicate, err := p.parseOrCondition()
+			if err != nil {
+				return nil, err
+			}
+			from.Sources = append(from.Sources, next)
+			from.Joins = append(from.Joins, JoinClause{Source: next, On: onPredicate})
+			continue
+		}
+
+		break
+	}
+	return from, nil
+}
+
+// parseFromSource parses a single entry of a FROM clause: a literal or
+// bound address, a parenthesized subquery, or a reference to a WITH
+// clause binding or a persisted CREATE VIEW, each optionally followed by
+// an alias.
+func (p *Parser) parseFromSource() (FromSource, error) {
+	if p.peek() == "(" {
+		return p.parseSubquerySource()
+	}
+	if name, ok := p.peekCTE(); ok {
+		return p.parseCTESource(name)
+	}
+	if name, ok := p.peekNamedSource(); ok {
+		return p.parseCTESource(name)
+	}
+
+	var address *big.Int
+	var param string
+	var err error
+	if strings.HasPrefix(p.peek(), "?") {
+		if param, err = p.eatParam(); err != nil {
+			return FromSource{}, err
+		}
+	} else {
+		if address, err = p.parseHex(); err != nil {
+			return FromSource{}, err
+		}
+	}
+	alias, err := p.parseOptionalAlias()
+	if err != nil {
+		return FromSource{}, err
+	}
+	return FromSource{Address: address, Param: param, Alias: alias}, nil
+}
+
+// peekNamedSource reports whether the current token could name a
+// persisted CREATE VIEW, without consuming it. Unlike peekCTE, this has
+// no set of known names to check against at parse time: a query is
+// parsed before the Alerter's view table is necessarily available to it,
+// so any bare identifier here is optimistically treated as a named
+// source and left for ResolveSources to resolve against both the WITH
+// clause's CTEs and persisted views, erroring only then if it is
+// neither.
+func (p *Parser) peekNamedSource() (string, bool) {
+	token := p.peek()
+	if !IsValidIdentifier(token) || caseInsensitiveTokens[token] {
+		return "", false
+	}
+	return token, true
+}
+
+// peekCTE reports whether the current token names a CTE visible to this
+// parse (one registered by an enclosing WITH clause), without consuming
+// it. It does not see CREATE VIEW definitions persisted to disk; those
+// are resolved later, against the Alerter's view table, by
+// ResolveSources.
+func (p *Parser) peekCTE() (string, bool) {
+	token := p.peek()
+	if p.ctes == nil {
+		return "", false
+	}
+	_, ok := p.ctes[token]
+	return token, ok
+}
+
+// parseSubquerySource parses a parenthesized subquery used as a FROM
+// source, e.g. "(select ... from 0x...) as t"
+func (p *Parser) parseSubquerySource() (FromSource, error) {
+	if err := p.eat("("); err != nil {
+		return FromSource{}, err
+	}
+	query, err := p.ParseSelect()
+	if err != nil {
+		return FromSource{}, err
+	}
+	if err := p.eat(")"); err != nil {
+		return FromSource{}, err
+	}
+	alias, err := p.parseOptionalAlias()
+	if err != nil {
+		return FromSource{}, err
+	}
+	return FromSource{Subquery: query, Alias: alias}, nil
+}
+
+// parseCTESource parses a reference to a previously-declared WITH clause
+// binding by name, e.g. "recent t"
+func (p *Parser) parseCTESource(name string) (FromSource, error) {
+	if err := p.advance(); err != nil {
+		return FromSource{}, err
+	}
+	alias, err := p.parseOptionalAlias()
+	if err != nil {
+		return FromSource{}, err
+	}
+	return FromSource{CTEName: name, Alias: alias}, nil
+}
+
+// parseOptionalAlias consumes an optional alias following a FROM source:
+// either a bare identifier, as plain address sources accept, or one
+// introduced by AS, as used after a subquery
+func (p *Parser) parseOptionalAlias() (string, error) {
+	if p.peek() == "as" {
+		return p.parseAs()
+	}
+	if token := p.peek(); IsValidIdentifier(token) && !caseInsensitiveTokens[token] {
+		return p.eatIdentifier()
+	}
+	return "", nil
 }
 
 func (p *Parser) parseWhere() (Predicate, error) {
@@ -169,7 +465,79 @@ func (p *Parser) parseWhere() (Predicate, error) {
 	return p.parseOrCondition()
 }
 
+// parseBlockRef parses a SINCE/UNTIL reference, which can be a plain block
+// number, an RFC3339 timestamp literal, NOW() [- INTERVAL n unit], an
+// explicit INTERVAL n unit [AGO], or "n days|hours|minutes ago"
 func (p *Parser) parseBlockRef() (*BlockRef, error) {
+	token := p.peek()
+
+	if strings.HasPrefix(token, "?") {
+		param, err := p.eatParam()
+		if err != nil {
+			return nil, err
+		}
+		return &BlockRef{Param: param}, nil
+	}
+
+	if token == "now" {
+		if err := p.eat("now"); err != nil {
+			return nil, err
+		}
+		if err := p.eat("("); err != nil {
+			return nil, err
+		}
+		if err := p.eat(")"); err != nil {
+			return nil, err
+		}
+		if p.peek() != "-" {
+			return NewTimestampBlockRef(time.Now().Unix()), nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		duration, err := p.parseInterval()
+		if err != nil {
+			return nil, err
+		}
+		return NewTimestampBlockRef(time.Now().Add(-duration).Unix()), nil
+	}
+
+	if strings.HasPrefix(token, "\"") {
+		str, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp literal %s: %v", token, err)
+		}
+		return NewTimestampBlockRef(timestamp.Unix()), p.advance()
+	}
+
+	if token == "interval" {
+		duration, err := p.parseInterval()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == "ago" {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		return NewDurationBlockRef(duration), nil
+	}
+
+	if StartsWithDigit(token) && isDurationUnit(p.peekN(1)) {
+		duration, err := p.parseDurationLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.eat("ago"); err != nil {
+			return nil, err
+		}
+		return NewDurationBlockRef(duration), nil
+	}
+
 	blockNum, err := p.eatIntLiteral()
 	if err != nil {
 		return nil, err
@@ -177,6 +545,47 @@ func (p *Parser) parseBlockRef() (*BlockRef, error) {
 	return NewBlockRef(blockNum), nil
 }
 
+// parseInterval parses "INTERVAL n unit" and returns the corresponding duration
+func (p *Parser) parseInterval() (time.Duration, error) {
+	if err := p.eat("interval"); err != nil {
+		return 0, err
+	}
+	return p.parseDurationLiteral()
+}
+
+func isDurationUnit(token string) bool {
+	switch token {
+	case "day", "days", "hour", "hours", "minute", "minutes":
+		return true
+	}
+	return false
+}
+
+// parseDurationLiteral parses "n unit" where unit is one of
+// day(s)/hour(s)/minute(s) and returns the corresponding duration
+func (p *Parser) parseDurationLiteral() (time.Duration, error) {
+	count, err := p.eatIntLiteral()
+	if err != nil {
+		return 0, err
+	}
+	unit := p.peek()
+	var unitDuration time.Duration
+	switch unit {
+	case "day", "days":
+		unitDuration = 24 * time.Hour
+	case "hour", "hours":
+		unitDuration = time.Hour
+	case "minute", "minutes":
+		unitDuration = time.Minute
+	default:
+		return 0, fmt.Errorf("expected a time unit (days, hours, minutes), got %s", unit)
+	}
+	if err := p.advance(); err != nil {
+		return 0, err
+	}
+	return time.Duration(count) * unitDuration, nil
+}
+
 // parseSelectList returns the expressions to be selected and
 // a mapping of alias to expression
 func (p *Parser) parseSelectList() (expressions []Expression, aliases map[string]Expression, err error) {
@@ -199,7 +608,7 @@ func (p *Parser) parseSelectList() (expressions []Expression, aliases map[string
 // parseSelectElem parses an element from the select list of the form
 // expresion [as alias]
 func (p *Parser) parseSelectElem(expressions *[]Expression, aliases map[string]Expression) error {
-	expression, err := p.parseExpression()
+	expression, err := p.parseBitwise()
 	if err != nil {
 		return err
 	}
@@ -287,6 +696,45 @@ func (p *Parser) parseGroupByElem(groupBy *GroupByClause) error {
 	return nil
 }
 
+// parseOrderBy parses a comma-separated ORDER BY list: expr [asc|desc], ...
+func (p *Parser) parseOrderBy() ([]OrderByElem, error) {
+	elem, err := p.parseOrderByElem()
+	if err != nil {
+		return nil, err
+	}
+	elems := []OrderByElem{elem}
+	for p.peek() == "," {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseOrderByElem()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+func (p *Parser) parseOrderByElem() (OrderByElem, error) {
+	expr, err := p.parseBitwise()
+	if err != nil {
+		return OrderByElem{}, err
+	}
+	desc := false
+	if p.peek() == "asc" {
+		if err := p.advance(); err != nil {
+			return OrderByElem{}, err
+		}
+	} else if p.peek() == "desc" {
+		desc = true
+		if err := p.advance(); err != nil {
+			return OrderByElem{}, err
+		}
+	}
+	return OrderByElem{Expr: expr, Desc: desc}, nil
+}
+
 func (p *Parser) parseOrCondition() (Predicate, error) {
 	predicate, err := p.parseAndCondition()
 	if err != nil {
@@ -306,11 +754,7 @@ func (p *Parser) parseOrConditionRec(left Predicate) (Predicate, error) {
 		if err != nil {
 			return nil, err
 		}
-		app, err := NewBoolBinaryApplication(left, right, token)
-		if err != nil {
-			return nil, err
-		}
-		return p.parseOrConditionRec(app)
+		return p.parseOrConditionRec(NewLogicOrApplication(left, right))
 	}
 	return left, nil
 }
@@ -334,11 +778,7 @@ func (p *Parser) parseAndConditionRec(left Predicate) (Predicate, error) {
 		if err != nil {
 			return nil, err
 		}
-		app, err := NewBoolBinaryApplication(left, right, token)
-		if err != nil {
-			return nil, err
-		}
-		return p.parseAndConditionRec(app)
+		return p.parseAndConditionRec(NewLogicAndApplication(left, right))
 	}
 	return left, nil
 }
@@ -372,7 +812,7 @@ func (p *Parser) parseSimplePredicate() (Predicate, error) {
 		return predicate, nil
 	}
 
-	exp, err := p.parseExpression()
+	exp, err := p.parseBitwise()
 	if err != nil {
 		return nil, err
 	}
@@ -422,7 +862,7 @@ func (p *Parser) parseSimplePredicate() (Predicate, error) {
 		if err := p.advance(); err != nil {
 			return nil, err
 		}
-		right, err := p.parseExpression()
+		right, err := p.parseBitwise()
 		if err != nil {
 			return nil, err
 		}
@@ -446,6 +886,43 @@ func (p *Parser) parseIn(needle Expression) (Predicate, error) {
 	return NewInOperator(needle, haystack), nil
 }
 
+// parseBitwise parses bitwise/shift operators (& | ^ << >>), which bind
+// looser than the arithmetic operators handled by parseExpression
+func (p *Parser) parseBitwise() (Expression, error) {
+	exp, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseRecBitwise(exp)
+}
+
+func (p *Parser) parseRecBitwise(left Expression) (Expression, error) {
+	token := p.peek()
+	if isBitwiseOperator(token) {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		app, err := NewIntBinaryApplication(left, right, token)
+		if err != nil {
+			return nil, err
+		}
+		return p.parseRecBitwise(app)
+	}
+	return left, nil
+}
+
+func isBitwiseOperator(token string) bool {
+	switch token {
+	case "&", "|", "^", "<<", ">>":
+		return true
+	}
+	return false
+}
+
 func (p *Parser) parseExpression() (Expression, error) {
 	term, err := p.parseTerm()
 	if err != nil {
@@ -504,7 +981,7 @@ func (p *Parser) parseRecTerm(left Expression) (Expression, error) {
 
 func (p *Parser) parseUnary() (Expression, error) {
 	token := p.peek()
-	if token == "+" || token == "-" {
+	if token == "+" || token == "-" || token == "~" {
 		if err := p.advance(); err != nil {
 			return nil, err
 		}
@@ -538,11 +1015,17 @@ func (p *Parser) parseFactor() (Expression, error) {
 		return NewIntValue(value), p.advance()
 	} else if token == "(" {
 		p.advance()
-		exp, err := p.parseExpression()
+		exp, err := p.parseBitwise()
 		if err != nil {
 			return nil, err
 		}
 		return exp, p.eat(")")
+	} else if strings.HasPrefix(token, "?") { // bind parameter
+		name, err := p.eatParam()
+		if err != nil {
+			return nil, err
+		}
+		return NewBindParamExpr(name), nil
 	} else if IsValidIdentifier(token) {
 		if p.peekN(1) == "(" {
 			return p.parseFuncCall()
@@ -573,14 +1056,105 @@ func (p *Parser) parseFuncCall() (Expression, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewFunctionCall(funcName, args), nil
+	if p.peek() == "over" {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("windowed aggregate %s expects exactly 1 argument, got %d", funcName, len(args))
+		}
+		return p.parseWindowFunctionCall(funcName, args[0])
+	}
+	return NewFunctionCall(funcName, args)
+}
+
+// parseWindowFunctionCall parses the "over (...)" clause following a
+// windowed aggregate call, e.g.
+// sum(tx.value) over (partition by tx.from rows between 10 preceding and current row)
+func (p *Parser) parseWindowFunctionCall(funcName string, argument Expression) (Expression, error) {
+	if err := p.eat("over"); err != nil {
+		return nil, err
+	}
+	if err := p.eat("("); err != nil {
+		return nil, err
+	}
+
+	window := &WindowSpec{}
+	if p.peek() == "partition by" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		partitionBy, err := p.parsePartitionByList()
+		if err != nil {
+			return nil, err
+		}
+		window.PartitionBy = partitionBy
+	}
+
+	if p.peek() == "rows" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.eat("between"); err != nil {
+			return nil, err
+		}
+		preceding, err := p.eatIntLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.eat("preceding"); err != nil {
+			return nil, err
+		}
+		if err := p.eat("and"); err != nil {
+			return nil, err
+		}
+		if err := p.eat("current"); err != nil {
+			return nil, err
+		}
+		if err := p.eat("row"); err != nil {
+			return nil, err
+		}
+		window.Preceding = preceding
+	}
+
+	if err := p.eat(")"); err != nil {
+		return nil, err
+	}
+
+	return NewWindowFunctionCall(funcName, argument, window)
+}
+
+// parsePartitionByList parses the comma-separated attribute list of a
+// PARTITION BY clause
+func (p *Parser) parsePartitionByList() ([]Expression, error) {
+	attribute, err := p.parseAttribute()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expression{attribute}
+	for p.peek() == "," {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		attribute, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, attribute)
+	}
+	return exprs, nil
 }
 
 func (p *Parser) parseExpList() ([]Expression, error) {
 	var arguments []Expression
-	for i := 0; (i == 0 && p.peek() == "(") || (i > 0 && p.peek() == ","); i++ {
-		p.advance()
-		exp, err := p.parseExpression()
+	if err := p.eat("("); err != nil {
+		return nil, err
+	}
+	if p.peek() == ")" {
+		return arguments, p.advance()
+	}
+	for i := 0; i == 0 || p.peek() == ","; i++ {
+		if i > 0 {
+			p.advance()
+		}
+		exp, err := p.parseBitwise()
 		if err != nil {
 			return nil, err
 		}
@@ -632,8 +1206,12 @@ func (p *Parser) isDone() bool {
 func (p *Parser) readToken() (bool, error) {
 	token, hasNext, err := p.Lexer.NextToken()
 	if err != nil {
+		logger.Debug("parser token error", "err", err)
 		return false, err
 	}
+	if hasNext {
+		logger.Trace("parser token", "token", token)
+	}
 	p.hasNext = hasNext
 	if hasNext {
 		p.buffer = append(p.buffer, token)
@@ -670,6 +1248,16 @@ func (p *Parser) eat(token string) error {
 	return p.advance()
 }
 
+// eatParam consumes a "?name" bind-parameter token, returning name without
+// its leading '?'
+func (p *Parser) eatParam() (string, error) {
+	token := p.peek()
+	if !strings.HasPrefix(token, "?") || !IsValidIdentifier(token[1:]) {
+		return "", fmt.Errorf("expected bind parameter (?name) but got %s", token)
+	}
+	return token[1:], p.advance()
+}
+
 func (p *Parser) eatIntLiteral() (res int64, err error) {
 	token := p.peek()
 	if strings.HasPrefix(token, "0x") {
@@ -3,6 +3,7 @@ package alerter
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -27,7 +28,7 @@ var (
 	ten         = NewIntValue(big.NewInt(10))
 	msgValue    = NewAttribute([]string{"msg", "value"})
 	msgSender   = NewAttribute([]string{"msg", "sender"})
-	sumMsgValue = NewFunctionCall("sum", []Expression{msgValue})
+	sumMsgValue = MustNewFunctionCall("sum", []Expression{msgValue})
 )
 
 func TestParseFactor(t *testing.T) {
@@ -38,7 +39,7 @@ func TestParseFactor(t *testing.T) {
 		"simple_attr":       NewAttribute([]string{"simple_attr"}),
 		"msg.value":         msgValue,
 		"op.call.arg.value": NewAttribute([]string{"op", "call", "arg", "value"}),
-		"SUM(msg.value)":    NewFunctionCall("sum", []Expression{msgValue}),
+		"SUM(msg.value)":    MustNewFunctionCall("sum", []Expression{msgValue}),
 		"(1)":               one,
 	}
 	for input, expected := range testCases {
@@ -55,7 +56,7 @@ func TestParseUnary(t *testing.T) {
 		"1":               one,
 		"-1":              MustNewIntUnaryApplication(one, "-"),
 		"-msg.value":      MustNewIntUnaryApplication(msgValue, "-"),
-		"+SUM(msg.value)": MustNewIntUnaryApplication(NewFunctionCall("sum", []Expression{msgValue}), "+"),
+		"+SUM(msg.value)": MustNewIntUnaryApplication(MustNewFunctionCall("sum", []Expression{msgValue}), "+"),
 	}
 	for input, expected := range testCases {
 		parser, err := NewParser(NewLexer(input))
@@ -72,7 +73,7 @@ func TestParseTerm(t *testing.T) {
 		"msg.value / 10": MustNewIntBinaryApplication(msgValue, ten, "/"),
 		"-SUM(msg.value) * 10 / COUNT(tx)": MustNewIntBinaryApplication(
 			MustNewIntBinaryApplication(MustNewIntUnaryApplication(sumMsgValue, "-"), ten, "*"),
-			NewFunctionCall("count", []Expression{NewAttribute([]string{"tx"})}),
+			MustNewFunctionCall("count", []Expression{NewAttribute([]string{"tx"})}),
 			"/",
 		),
 	}
@@ -93,7 +94,7 @@ func TestParseExpression(t *testing.T) {
 			MustNewIntBinaryApplication(one,
 				MustNewIntBinaryApplication(
 					MustNewIntBinaryApplication(MustNewIntUnaryApplication(sumMsgValue, "-"), ten, "*"),
-					NewFunctionCall("count", []Expression{NewAttribute([]string{"tx"})}),
+					MustNewFunctionCall("count", []Expression{NewAttribute([]string{"tx"})}),
 					"/",
 				),
 				"+",
@@ -111,6 +112,23 @@ func TestParseExpression(t *testing.T) {
 	}
 }
 
+func TestParseBitwise(t *testing.T) {
+	testCases := map[string]Expression{
+		"msg.value & 0xff":       MustNewIntBinaryApplication(msgValue, NewIntValue(big.NewInt(0xff)), "&"),
+		"1 | 2 ^ 3":              MustNewIntBinaryApplication(MustNewIntBinaryApplication(one, NewIntValue(big.NewInt(2)), "|"), NewIntValue(big.NewInt(3)), "^"),
+		"1 << 8 >> 1":            MustNewIntBinaryApplication(MustNewIntBinaryApplication(one, NewIntValue(big.NewInt(8)), "<<"), one, ">>"),
+		"~msg.value":             MustNewIntUnaryApplication(msgValue, "~"),
+		"msg.value + 1 & 0xff00": MustNewIntBinaryApplication(MustNewIntBinaryApplication(msgValue, one, "+"), NewIntValue(big.NewInt(0xff00)), "&"),
+	}
+	for input, expected := range testCases {
+		parser, err := NewParser(NewLexer(input))
+		assert.Nil(t, err)
+		exp, err := parser.parseBitwise()
+		assert.Nil(t, err)
+		assert.True(t, expected.Equals(exp), "%s: %v != %v", input, expected, exp)
+	}
+}
+
 func TestBasicSelect(t *testing.T) {
 	query := "select sum(msg.value) / 10 as sum, count(tx) from 0x1234abcd"
 	parser, err := NewParser(NewLexer(query))
@@ -122,7 +140,7 @@ func TestBasicSelect(t *testing.T) {
 	firstExp := MustNewIntBinaryApplication(sumMsgValue, ten, "/")
 	assert.True(t, firstExp.Equals(stmt.Selected[0]), "%v != %v", firstExp, stmt.Selected[0])
 	assert.True(t, firstExp.Equals(stmt.Aliases["sum"]), "%v != %v", firstExp, stmt.Aliases["sum"])
-	secondExp := NewFunctionCall("count", []Expression{NewAttribute([]string{"tx"})})
+	secondExp := MustNewFunctionCall("count", []Expression{NewAttribute([]string{"tx"})})
 	assert.True(t, secondExp.Equals(stmt.Selected[1]), "%v != %v", secondExp, stmt.Selected[1])
 	expectedAddress, _ := big.NewInt(0).SetString("1234abcd", 16)
 	assert.Equal(t, expectedAddress, stmt.From.Address)
@@ -137,23 +155,20 @@ func TestSelectWithWhere(t *testing.T) {
 	assert.Nil(t, err)
 	stmt, err := parser.ParseSelect()
 	assert.Nil(t, err)
-	expected := MustNewBoolBinaryApplication(
+	expected := NewLogicAndApplication(
 		MustNewCompBinaryApplication(sumMsgValue, ten, ">"),
 		NegatePredicate(
-			MustNewBoolBinaryApplication(
-				MustNewBoolBinaryApplication(
+			NewLogicOrApplication(
+				NewLogicOrApplication(
 					NegatePredicate(NewIsOperator(msgSender, "address")),
 					MustNewCompBinaryApplication(msgSender, NewIntValue(big.NewInt(0x54321)), "="),
-					"or",
 				),
 				NewInOperator(msgSender, []Expression{
 					NewIntValue(big.NewInt(0x123)),
 					NewIntValue(big.NewInt(0x432)),
 				}),
-				"or",
 			),
 		),
-		"and",
 	)
 	assert.True(t, expected.Equals(stmt.Where), "expected != actual:\n%v != %v", expected, stmt.Where)
 }
@@ -193,3 +208,254 @@ func TestFullStatement(t *testing.T) {
 	assert.Equal(t, int64(2), *stmt.Offset)
 	assert.Equal(t, int64(3), *stmt.GroupBy.BlocksCount)
 }
+
+func TestSinceUntilTimestamps(t *testing.T) {
+	query := `select tx.hash from 0x1234abcd
+		since "2024-01-10T00:00:00Z" until now() - interval 1 hour`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	expectedSince, err := time.Parse(time.RFC3339, "2024-01-10T00:00:00Z")
+	assert.Nil(t, err)
+	assert.NotNil(t, stmt.Since.Timestamp)
+	assert.Equal(t, expectedSince.Unix(), *stmt.Since.Timestamp)
+
+	assert.NotNil(t, stmt.Until.Timestamp)
+	assert.InDelta(t, time.Now().Add(-time.Hour).Unix(), *stmt.Until.Timestamp, 5)
+}
+
+func TestFromWithJoin(t *testing.T) {
+	query := `select a.msg.value from 0x1234abcd a join 0x54321 b on a.tx.hash = b.tx.hash`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	expectedA, _ := big.NewInt(0).SetString("1234abcd", 16)
+	assert.Equal(t, expectedA, stmt.From.Address)
+	assert.Len(t, stmt.From.Sources, 2)
+	assert.Equal(t, "a", stmt.From.Sources[0].Alias)
+	assert.Equal(t, "b", stmt.From.Sources[1].Alias)
+	assert.Len(t, stmt.From.Joins, 1)
+
+	resolved, ok := stmt.From.ResolveAlias("b")
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(0x54321), resolved)
+
+	expectedOn := MustNewCompBinaryApplication(
+		NewAttribute([]string{"a", "tx", "hash"}),
+		NewAttribute([]string{"b", "tx", "hash"}),
+		"=",
+	)
+	assert.True(t, expectedOn.Equals(stmt.From.Joins[0].On))
+}
+
+func TestSinceDurationAgo(t *testing.T) {
+	query := `select tx.hash from 0x1234abcd since 7 days ago`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+	assert.NotNil(t, stmt.Since.Duration)
+	assert.Equal(t, 7*24*time.Hour, *stmt.Since.Duration)
+}
+
+func TestParseBindParams(t *testing.T) {
+	query := `select tx.hash from ?address since ?since until ?until limit ?limit`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.Nil(t, stmt.From.Address)
+	assert.Equal(t, "address", stmt.From.Sources[0].Param)
+	assert.Equal(t, "since", stmt.Since.Param)
+	assert.Equal(t, "until", stmt.Until.Param)
+	assert.Nil(t, stmt.Limit)
+	assert.Equal(t, "limit", stmt.LimitParam)
+}
+
+func TestSelectWithOrderBy(t *testing.T) {
+	query := `select msg.value from 0x1234abcd order by msg.value desc, msg.sender`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.Len(t, stmt.OrderBy, 2)
+	assert.True(t, msgValue.Equals(stmt.OrderBy[0].Expr))
+	assert.True(t, stmt.OrderBy[0].Desc)
+	assert.True(t, msgSender.Equals(stmt.OrderBy[1].Expr))
+	assert.False(t, stmt.OrderBy[1].Desc)
+}
+
+func TestSelectWithHaving(t *testing.T) {
+	query := `select SUM(msg.value) from 0x1234abcd group by msg.sender having SUM(msg.value) > 10`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.NotNil(t, stmt.GroupBy)
+	assert.NotNil(t, stmt.Having)
+
+	expectedHaving := MustNewCompBinaryApplication(sumMsgValue, ten, ">")
+	assert.True(t, expectedHaving.Equals(stmt.Having))
+}
+
+func TestSelectWithWindowFunction(t *testing.T) {
+	query := `select sum(msg.value) over (partition by msg.sender rows between 10 preceding and current row) from 0x1234abcd`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.Len(t, stmt.Selected, 1)
+	windowCall, ok := stmt.Selected[0].(*WindowFunctionCall)
+	assert.True(t, ok)
+	assert.Equal(t, "sum", windowCall.FunctionName)
+	assert.True(t, msgValue.Equals(windowCall.Argument))
+	assert.Len(t, windowCall.Window.PartitionBy, 1)
+	assert.True(t, msgSender.Equals(windowCall.Window.PartitionBy[0]))
+	assert.Equal(t, int64(10), windowCall.Window.Preceding)
+}
+
+func TestSelectWithWindowFunctionNoPartition(t *testing.T) {
+	query := `select count(msg.value) over (rows between 5 preceding and current row) from 0x1234abcd`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	windowCall, ok := stmt.Selected[0].(*WindowFunctionCall)
+	assert.True(t, ok)
+	assert.Empty(t, windowCall.Window.PartitionBy)
+	assert.Equal(t, int64(5), windowCall.Window.Preceding)
+}
+
+// TestTwoWordKeywordLookahead exercises the lexer's LL(2) two-word-token
+// folding across all three of its keywords in a single statement, checking
+// that "group by", "order by" and "partition by" are each disambiguated
+// from one another by the lexer's two-word folding rather than one
+// swallowing tokens meant for another.
+func TestTwoWordKeywordLookahead(t *testing.T) {
+	query := `
+		select sum(msg.value) over (partition by msg.sender rows between 2 preceding and current row)
+		from 0x1234abcd
+		group by msg.sender
+		order by msg.value desc`
+
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	windowCall, ok := stmt.Selected[0].(*WindowFunctionCall)
+	assert.True(t, ok)
+	assert.Len(t, windowCall.Window.PartitionBy, 1)
+
+	assert.NotNil(t, stmt.GroupBy)
+	assert.Len(t, stmt.GroupBy.Attributes, 1)
+
+	assert.Len(t, stmt.OrderBy, 1)
+	assert.True(t, stmt.OrderBy[0].Desc)
+}
+
+func TestSelectWithSubquerySource(t *testing.T) {
+	query := `select t.msg.value from (select msg.value from 0x1234abcd) as t`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.Len(t, stmt.From.Sources, 1)
+	source := stmt.From.Sources[0]
+	assert.Equal(t, "t", source.Alias)
+	assert.Empty(t, source.CTEName)
+	assert.NotNil(t, source.Subquery)
+	assert.Len(t, source.Subquery.Selected, 1)
+}
+
+func TestSelectWithCTE(t *testing.T) {
+	query := `
+		with recent as (select msg.value from 0x1234abcd),
+		     doubled as (select value from recent)
+		select value from doubled`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.Len(t, stmt.With, 2)
+	assert.Equal(t, "recent", stmt.With[0].Name)
+	assert.Equal(t, "doubled", stmt.With[1].Name)
+
+	// "doubled" references "recent" by name, so its own FROM source should
+	// already be recognized as a CTE reference at parse time
+	doubledFrom := stmt.With[1].Query.From.Sources[0]
+	assert.Equal(t, "recent", doubledFrom.CTEName)
+
+	assert.Len(t, stmt.From.Sources, 1)
+	assert.Equal(t, "doubled", stmt.From.Sources[0].CTEName)
+}
+
+func TestParseStatementCreateView(t *testing.T) {
+	query := `create view recent as select msg.value from 0x1234abcd`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	statement, err := parser.ParseStatement()
+	assert.Nil(t, err)
+
+	createView, ok := statement.(*CreateViewStatement)
+	assert.True(t, ok)
+	assert.Equal(t, "recent", createView.Name)
+	assert.NotNil(t, createView.Query)
+}
+
+func TestParseStatementPlainSelect(t *testing.T) {
+	query := `select msg.value from 0x1234abcd`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	statement, err := parser.ParseStatement()
+	assert.Nil(t, err)
+
+	_, ok := statement.(*SelectStatement)
+	assert.True(t, ok)
+}
+
+func TestResolveSourcesCTE(t *testing.T) {
+	query := `with recent as (select msg.value from 0x1234abcd) select value from recent`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	assert.Nil(t, ResolveSources(stmt, nil))
+	source := stmt.From.Sources[0]
+	assert.Empty(t, source.CTEName)
+	assert.Equal(t, stmt.With[0].Query, source.Subquery)
+}
+
+func TestResolveSourcesView(t *testing.T) {
+	query := `select value from a_view`
+	parser, err := NewParser(NewLexer(query))
+	assert.Nil(t, err)
+	stmt, err := parser.ParseSelect()
+	assert.Nil(t, err)
+
+	viewQuery := &SelectStatement{}
+	views := map[string]*SelectStatement{"a_view": viewQuery}
+	assert.Nil(t, ResolveSources(stmt, views))
+	assert.Equal(t, viewQuery, stmt.From.Sources[0].Subquery)
+}
+
+func TestResolveSourcesUndefined(t *testing.T) {
+	stmt := &SelectStatement{
+		From: &FromClause{Sources: []FromSource{{CTEName: "unknown_source"}}},
+	}
+
+	err := ResolveSources(stmt, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,94 @@
+package alerter
+
+import "fmt"
+
+// ResolveSources substitutes every FromSource.CTEName in stmt's FROM clause
+// (and any JOINed sources) with the matching subquery, looked up first
+// against stmt's own WITH-clause definitions, then against views. This is
+// the only planning step performed before execution: there is no generic
+// query planner in this codebase, so nested subqueries and CREATE VIEW
+// definitions are resolved recursively by this single function rather than
+// a separate tree-walking pass.
+func ResolveSources(stmt *SelectStatement, views map[string]*SelectStatement) error {
+	return resolveSources(stmt, nil, views)
+}
+
+// resolveSources is ResolveSources with outer holding the CTEs visible from
+// enclosing statements. Each CTE body is resolved against the CTEs defined
+// before it, so a CTE may reference an earlier one but not itself.
+func resolveSources(stmt *SelectStatement, outer map[string]*SelectStatement, views map[string]*SelectStatement) error {
+	ctes := make(map[string]*SelectStatement, len(outer)+len(stmt.With))
+	for name, query := range outer {
+		ctes[name] = query
+	}
+	for _, def := range stmt.With {
+		if err := resolveSources(def.Query, ctes, views); err != nil {
+			return err
+		}
+		ctes[def.Name] = def.Query
+	}
+	if stmt.From == nil {
+		return nil
+	}
+
+	for i := range stmt.From.Sources {
+		if err := resolveSource(&stmt.From.Sources[i], ctes, views); err != nil {
+			return err
+		}
+	}
+	for i := range stmt.From.Joins {
+		if err := resolveSource(&stmt.From.Joins[i].Source, ctes, views); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSource resolves a single FromSource in place, recursing into its
+// Subquery (if any) so nested WITH clauses and FROM-by-name references are
+// resolved too. A view's body sees only its own CTEs, not the caller's.
+func resolveSource(source *FromSource, ctes map[string]*SelectStatement, views map[string]*SelectStatement) error {
+	if source.CTEName != "" {
+		query, ok := ctes[source.CTEName]
+		if !ok {
+			query, ok = views[source.CTEName]
+			ctes = nil
+		}
+		if !ok {
+			return fmt.Errorf("undefined CTE or view %q", source.CTEName)
+		}
+		source.CTEName = ""
+		source.Subquery = query
+	}
+	if source.Subquery != nil {
+		return resolveSources(source.Subquery, ctes, views)
+	}
+	return nil
+}
+
+// rewriteAliasedAttribute substitutes attr with the expression it refers to
+// in a subquery's SELECT list, if attr's first part matches alias and the
+// rest names one of the subquery's Aliases, e.g. rewriting "a.total" to the
+// expression aliased as "total" by a subquery aliased "a". It returns attr
+// unchanged, and false, if there is no such match.
+//
+// This only rewrites a bare *Attribute node; it does not recurse through
+// compound Predicate/Expression trees (AND/OR/comparisons/function calls),
+// since this codebase has no generic AST visitor to drive that traversal
+// (see constfold.go for the same limitation in constant folding). Callers
+// needing to rewrite attributes nested inside larger expressions must walk
+// those trees themselves, node type by node type.
+func rewriteAliasedAttribute(attr *Attribute, alias string, subquery *SelectStatement) (Expression, bool) {
+	if len(attr.Parts) < 2 || attr.Parts[0] != alias {
+		return attr, false
+	}
+	name := attr.Parts[len(attr.Parts)-1]
+	if len(attr.Parts) > 2 {
+		return attr, false
+	}
+	aliased, ok := subquery.Aliases[name]
+	if !ok {
+		return attr, false
+	}
+	return aliased, true
+}
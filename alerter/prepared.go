@@ -0,0 +1,465 @@
+package alerter
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// PreparedStatement is an immutable, reusable compiled form of a
+// SelectStatement returned by Prepare. Its FROM/SINCE/UNTIL/LIMIT clauses
+// may still hold unresolved ?name bind parameters; Bind and Execute
+// substitute concrete values for those without reparsing or re-validating
+// the query, so a caller that repeatedly runs the same query shape with
+// different Since/Until/Limit values (e.g. a dashboard) only pays parsing
+// and FunctionCall builtin resolution once.
+type PreparedStatement struct {
+	stmt *SelectStatement
+}
+
+// Prepare parses query into a PreparedStatement. ?name placeholders in
+// the FROM, SINCE, UNTIL and LIMIT clauses are left unresolved until Bind
+// or Execute supplies params; every other clause is compiled exactly as
+// ParseSelect would compile it, including NewFunctionCall's eager builtin
+// resolution and constant folding.
+func Prepare(query string) (*PreparedStatement, error) {
+	parser, err := NewParser(NewLexer(query))
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := parser.ParseSelect()
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedStatement{stmt: stmt}, nil
+}
+
+// Statement returns the compiled SelectStatement, with any ?name bind
+// parameters still unresolved. Most callers want Bind or Execute instead.
+func (p *PreparedStatement) Statement() *SelectStatement {
+	return p.stmt
+}
+
+// Bind substitutes params for every ?name placeholder found by Prepare,
+// returning a SelectStatement ready to evaluate. p is never mutated, so
+// the same PreparedStatement can be bound concurrently with different
+// params.
+func (p *PreparedStatement) Bind(params map[string]Value) (*SelectStatement, error) {
+	bound := *p.stmt
+
+	if p.stmt.From != nil {
+		from, err := bindFromClause(p.stmt.From, params)
+		if err != nil {
+			return nil, err
+		}
+		bound.From = from
+	}
+	if p.stmt.Since != nil {
+		since, err := bindBlockRef("since", p.stmt.Since, params)
+		if err != nil {
+			return nil, err
+		}
+		bound.Since = since
+	}
+	if p.stmt.Until != nil {
+		until, err := bindBlockRef("until", p.stmt.Until, params)
+		if err != nil {
+			return nil, err
+		}
+		bound.Until = until
+	}
+	if p.stmt.LimitParam != "" {
+		value, ok := params[p.stmt.LimitParam]
+		if !ok {
+			return nil, fmt.Errorf("missing bind parameter ?%s", p.stmt.LimitParam)
+		}
+		limit, err := valueAsInt(value)
+		if err != nil {
+			return nil, fmt.Errorf("bind parameter ?%s: %v", p.stmt.LimitParam, err)
+		}
+		limitValue := limit.Int64()
+		bound.Limit = &limitValue
+		bound.LimitParam = ""
+	}
+	if p.stmt.Where != nil {
+		where, err := substitutePredicate(p.stmt.Where, params)
+		if err != nil {
+			return nil, err
+		}
+		bound.Where = where
+	}
+	if p.stmt.Having != nil {
+		having, err := substitutePredicate(p.stmt.Having, params)
+		if err != nil {
+			return nil, err
+		}
+		bound.Having = having
+	}
+	if len(p.stmt.Selected) > 0 {
+		selected := make([]Expression, len(p.stmt.Selected))
+		for i, expression := range p.stmt.Selected {
+			substituted, err := substituteExpression(expression, params)
+			if err != nil {
+				return nil, err
+			}
+			selected[i] = substituted
+		}
+		bound.Selected = selected
+	}
+	if len(p.stmt.OrderBy) > 0 {
+		orderBy := make([]OrderByElem, len(p.stmt.OrderBy))
+		for i, elem := range p.stmt.OrderBy {
+			substituted, err := substituteExpression(elem.Expr, params)
+			if err != nil {
+				return nil, err
+			}
+			orderBy[i] = OrderByElem{Expr: substituted, Desc: elem.Desc}
+		}
+		bound.OrderBy = orderBy
+	}
+
+	return &bound, nil
+}
+
+// valueExpr wraps an already-resolved Value as an Expression, so
+// substituteExpression can splice a bind parameter's value into
+// arbitrary expression position even when that value is a BytesValue
+// (which, unlike IntValue/StringValue/BoolValue, has no EMQL literal
+// syntax of its own and so does not implement Expression directly).
+type valueExpr struct {
+	value Value
+}
+
+func (v *valueExpr) String() string {
+	return fmt.Sprintf("%v", v.value)
+}
+
+// Equals returns true if the value equals other
+func (v *valueExpr) Equals(rawOther interface{}) bool {
+	other, ok := rawOther.(*valueExpr)
+	return ok && v.value.Equals(other.value)
+}
+
+// Execute returns the wrapped value as is
+func (v *valueExpr) Execute(ctx context.Context, env *Env) (Value, error) {
+	return v.value, nil
+}
+
+// ExecuteBool lets a bound bool parameter stand in directly as a
+// Predicate, e.g. "WHERE ?enabled"
+func (v *valueExpr) ExecuteBool(ctx context.Context, env *Env) (bool, error) {
+	return valueAsBool(v.value)
+}
+
+// EvalInt converts the wrapped value to an int
+func (v *valueExpr) EvalInt(ctx context.Context, env *Env) (*big.Int, error) {
+	return valueAsInt(v.value)
+}
+
+// EvalBool converts the wrapped value to a bool
+func (v *valueExpr) EvalBool(ctx context.Context, env *Env) (bool, error) {
+	return valueAsBool(v.value)
+}
+
+// EvalString converts the wrapped value to a string
+func (v *valueExpr) EvalString(ctx context.Context, env *Env) (string, error) {
+	return valueAsString(v.value)
+}
+
+// substituteExpression walks expr, replacing every BindParamExpr leaf
+// with the literal Value bound to its name in params. Every other node
+// is rebuilt with its children substituted the same way; leaves with no
+// children (Attribute, IntValue, StringValue, BoolValue) are returned
+// unchanged.
+func substituteExpression(expr Expression, params map[string]Value) (Expression, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	if param, ok := expr.(*BindParamExpr); ok {
+		value, ok := params[param.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing bind parameter ?%s", param.Name)
+		}
+		return &valueExpr{value: value}, nil
+	}
+
+	switch e := expr.(type) {
+	case *PredBinaryApplication:
+		left, right, err := substitutePair(e.Left, e.Right, params)
+		if err != nil {
+			return nil, err
+		}
+		return &PredBinaryApplication{BinaryApplication: &BinaryApplication{Left: left, Right: right, Operator: e.Operator}}, nil
+	case *BinaryApplication:
+		left, right, err := substitutePair(e.Left, e.Right, params)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryApplication{Left: left, Right: right, Operator: e.Operator}, nil
+	case *PredUnaryApplication:
+		operand, err := substituteExpression(e.Operand, params)
+		if err != nil {
+			return nil, err
+		}
+		return &PredUnaryApplication{UnaryApplication: &UnaryApplication{Operand: operand, Operator: e.Operator}}, nil
+	case *UnaryApplication:
+		operand, err := substituteExpression(e.Operand, params)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryApplication{Operand: operand, Operator: e.Operator}, nil
+	case *LogicAndApplication:
+		left, right, err := substitutePredicatePair(e.Left, e.Right, params)
+		if err != nil {
+			return nil, err
+		}
+		return &LogicAndApplication{Left: left, Right: right}, nil
+	case *LogicOrApplication:
+		left, right, err := substitutePredicatePair(e.Left, e.Right, params)
+		if err != nil {
+			return nil, err
+		}
+		return &LogicOrApplication{Left: left, Right: right}, nil
+	case *InOperator:
+		needle, err := substituteExpression(e.Needle, params)
+		if err != nil {
+			return nil, err
+		}
+		haystack := make([]Expression, len(e.Haystack))
+		for i, candidate := range e.Haystack {
+			substituted, err := substituteExpression(candidate, params)
+			if err != nil {
+				return nil, err
+			}
+			haystack[i] = substituted
+		}
+		return &InOperator{Needle: needle, Haystack: haystack}, nil
+	case *IsOperator:
+		operand, err := substituteExpression(e.Operand, params)
+		if err != nil {
+			return nil, err
+		}
+		return &IsOperator{Operand: operand, Target: e.Target}, nil
+	case *FunctionCall:
+		arguments := make([]Expression, len(e.Arguments))
+		for i, argument := range e.Arguments {
+			substituted, err := substituteExpression(argument, params)
+			if err != nil {
+				return nil, err
+			}
+			arguments[i] = substituted
+		}
+		call := *e
+		call.Arguments = arguments
+		return &call, nil
+	case *WindowFunctionCall:
+		argument, err := substituteExpression(e.Argument, params)
+		if err != nil {
+			return nil, err
+		}
+		return &WindowFunctionCall{FunctionName: e.FunctionName, Argument: argument, Window: e.Window}, nil
+	default:
+		return expr, nil
+	}
+}
+
+// substitutePredicate is substituteExpression's Predicate-typed
+// counterpart, used for WHERE/HAVING
+func substitutePredicate(pred Predicate, params map[string]Value) (Predicate, error) {
+	if pred == nil {
+		return nil, nil
+	}
+	substituted, err := substituteExpression(pred, params)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := substituted.(Predicate)
+	if !ok {
+		return nil, fmt.Errorf("bind parameter substitution produced %v, which is not a predicate", substituted)
+	}
+	return result, nil
+}
+
+// substitutePair substitutes two Expression children, e.g. a
+// BinaryApplication's Left/Right
+func substitutePair(left, right Expression, params map[string]Value) (Expression, Expression, error) {
+	substitutedLeft, err := substituteExpression(left, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	substitutedRight, err := substituteExpression(right, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return substitutedLeft, substitutedRight, nil
+}
+
+// substitutePredicatePair is substitutePair's Predicate-typed
+// counterpart, for LogicAndApplication/LogicOrApplication's Left/Right
+func substitutePredicatePair(left, right Predicate, params map[string]Value) (Predicate, Predicate, error) {
+	substitutedLeft, err := substitutePredicate(left, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	substitutedRight, err := substitutePredicate(right, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return substitutedLeft, substitutedRight, nil
+}
+
+// Execute binds params into p and delegates to the resulting statement's
+// EvaluateContext, returning the projected Selected row (with Aliases
+// applied) when its WHERE clause (if any) matches env.Row. It is the
+// single-row counterpart to RunLiveQuery, for callers which already have a
+// Row in hand and just want to repeatedly re-run the same query shape
+// against it.
+func (p *PreparedStatement) Execute(ctx context.Context, env *Env, params map[string]Value) (Row, bool, error) {
+	stmt, err := p.Bind(params)
+	if err != nil {
+		return nil, false, err
+	}
+	return stmt.EvaluateContext(ctx, env)
+}
+
+// bindFromClause rebuilds from with every Param-bearing FromSource
+// resolved from params, returning from unchanged when it has none.
+func bindFromClause(from *FromClause, params map[string]Value) (*FromClause, error) {
+	needsBinding := false
+	for _, source := range from.Sources {
+		if source.Param != "" {
+			needsBinding = true
+			break
+		}
+	}
+	if !needsBinding {
+		return from, nil
+	}
+
+	bound := &FromClause{Joins: from.Joins, Sources: make([]FromSource, len(from.Sources))}
+	for i, source := range from.Sources {
+		if source.Param == "" {
+			bound.Sources[i] = source
+			continue
+		}
+		value, ok := params[source.Param]
+		if !ok {
+			return nil, fmt.Errorf("missing bind parameter ?%s", source.Param)
+		}
+		address, err := valueAsAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("bind parameter ?%s: %v", source.Param, err)
+		}
+		bound.Sources[i] = FromSource{Address: address, Alias: source.Alias}
+	}
+	bound.Address = bound.Sources[0].Address
+	return bound, nil
+}
+
+// bindBlockRef resolves ref's Param against params into an explicit-block
+// BlockRef, or returns ref unchanged if it is already a literal.
+func bindBlockRef(clause string, ref *BlockRef, params map[string]Value) (*BlockRef, error) {
+	if ref.Param == "" {
+		return ref, nil
+	}
+	value, ok := params[ref.Param]
+	if !ok {
+		return nil, fmt.Errorf("missing bind parameter ?%s for %s", ref.Param, clause)
+	}
+	blockNum, err := valueAsInt(value)
+	if err != nil {
+		return nil, fmt.Errorf("bind parameter ?%s: %v", ref.Param, err)
+	}
+	return NewBlockRef(blockNum.Int64()), nil
+}
+
+// valueAsAddress converts a bind parameter Value to the *big.Int address
+// representation FromSource uses, accepting either a raw address
+// (BytesValue) or an already-integral value.
+func valueAsAddress(value Value) (*big.Int, error) {
+	if value.IsBytes() {
+		return new(big.Int).SetBytes(value.ToBytes()), nil
+	}
+	if value.IsInt() {
+		return value.ToInt(), nil
+	}
+	return nil, fmt.Errorf("expected an address (bytes or int) but got %v", value)
+}
+
+// preparedStatementCacheSize bounds Env.Prepare's LRU cache, so an Env
+// evaluating many distinct ad-hoc query strings over its lifetime doesn't
+// retain all of their compiled forms indefinitely.
+const preparedStatementCacheSize = 128
+
+// preparedStatementCache is a small LRU cache of PreparedStatements keyed
+// by raw query string, backing Env.Prepare.
+type preparedStatementCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type preparedStatementCacheEntry struct {
+	query string
+	stmt  *PreparedStatement
+}
+
+func newPreparedStatementCache(capacity int) *preparedStatementCache {
+	return &preparedStatementCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *preparedStatementCache) get(query string) (*PreparedStatement, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, exists := c.entries[query]
+	if !exists {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*preparedStatementCacheEntry).stmt, true
+}
+
+func (c *preparedStatementCache) put(query string, stmt *PreparedStatement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exists := c.entries[query]; exists {
+		c.order.MoveToFront(elem)
+		elem.Value.(*preparedStatementCacheEntry).stmt = stmt
+		return
+	}
+	elem := c.order.PushFront(&preparedStatementCacheEntry{query: query, stmt: stmt})
+	c.entries[query] = elem
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*preparedStatementCacheEntry).query)
+	}
+}
+
+// Prepare compiles query into a PreparedStatement, reusing a cached
+// compilation from an earlier call against the same query string when
+// available. The cache is bounded (see preparedStatementCacheSize) and
+// evicts least-recently-used entries, so ad-hoc callers that repeatedly
+// evaluate the same query shape benefit without calling the package-level
+// Prepare themselves.
+func (e *Env) Prepare(query string) (*PreparedStatement, error) {
+	if e.preparedCache == nil {
+		e.preparedCache = newPreparedStatementCache(preparedStatementCacheSize)
+	}
+	if cached, ok := e.preparedCache.get(query); ok {
+		return cached, nil
+	}
+	stmt, err := Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	e.preparedCache.put(query, stmt)
+	return stmt, nil
+}
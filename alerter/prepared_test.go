@@ -0,0 +1,76 @@
+package alerter
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareBindsFromSinceLimit(t *testing.T) {
+	prepared, err := Prepare(`select msg.value from ?address since ?since limit ?limit`)
+	assert.NoError(t, err)
+
+	addr := common.HexToAddress("0x42")
+	stmt, err := prepared.Bind(map[string]Value{
+		"address": NewBytesValue(addr.Bytes()),
+		"since":   NewIntValue(big.NewInt(100)),
+		"limit":   NewIntValue(big.NewInt(5)),
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, addr.Big(), stmt.From.Address)
+	assert.Equal(t, int64(100), stmt.Since.BlockNum)
+	assert.Equal(t, int64(5), *stmt.Limit)
+	assert.Empty(t, stmt.LimitParam)
+}
+
+func TestPrepareBindMissingParamErrors(t *testing.T) {
+	prepared, err := Prepare(`select msg.value from ?address`)
+	assert.NoError(t, err)
+
+	_, err = prepared.Bind(map[string]Value{})
+	assert.Error(t, err)
+}
+
+func TestPrepareExecuteMatchesAndProjects(t *testing.T) {
+	prepared, err := Prepare(`select msg.value as v from ?address where msg.value > ?threshold`)
+	assert.NoError(t, err)
+
+	addr := common.HexToAddress("0x1")
+	env := &Env{Row: Row{"msg.value": NewIntValue(big.NewInt(10))}}
+	row, matched, err := prepared.Execute(context.Background(), env, map[string]Value{
+		"address":   NewBytesValue(addr.Bytes()),
+		"threshold": NewIntValue(big.NewInt(5)),
+	})
+	assert.NoError(t, err)
+	assert.True(t, matched)
+	assert.Equal(t, NewIntValue(big.NewInt(10)), row["v"])
+}
+
+func TestPrepareExecuteNoMatch(t *testing.T) {
+	prepared, err := Prepare(`select msg.value from ?address where msg.value > ?threshold`)
+	assert.NoError(t, err)
+
+	env := &Env{Row: Row{"msg.value": NewIntValue(big.NewInt(1))}}
+	_, matched, err := prepared.Execute(context.Background(), env, map[string]Value{
+		"address":   NewBytesValue(common.HexToAddress("0x1").Bytes()),
+		"threshold": NewIntValue(big.NewInt(5)),
+	})
+	assert.NoError(t, err)
+	assert.False(t, matched)
+}
+
+func TestEnvPrepareCachesByQuery(t *testing.T) {
+	env := &Env{}
+	query := `select msg.value from ?address`
+
+	first, err := env.Prepare(query)
+	assert.NoError(t, err)
+	second, err := env.Prepare(query)
+	assert.NoError(t, err)
+
+	assert.Same(t, first, second)
+}
@@ -0,0 +1,69 @@
+package alerter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HeaderSource is the minimal chain access needed to resolve a BlockRef.
+// It is satisfied by core.BlockChain.
+type HeaderSource interface {
+	CurrentHeader() *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+	GetHeaderByHash(hash common.Hash) *types.Header
+}
+
+// ResolveBlockRef returns the block number referenced by ref. Explicit
+// block numbers are returned as-is; timestamp/duration refs are resolved
+// by walking back from the current head (which may not be canonical, e.g.
+// right after a reorg) until a header at or before the target time is
+// found. A nil ref resolves to the current head when isUntil is set, which
+// is the behavior expected for a bare "UNTIL NOW()".
+func ResolveBlockRef(chain HeaderSource, ref *BlockRef, isUntil bool) (int64, error) {
+	if ref == nil {
+		if isUntil {
+			return chain.CurrentHeader().Number.Int64(), nil
+		}
+		return 0, fmt.Errorf("missing block reference")
+	}
+	if !ref.NeedsResolution() {
+		return ref.BlockNum, nil
+	}
+
+	target := resolveTimestamp(ref)
+	head := chain.CurrentHeader()
+	if target >= int64(head.Time) {
+		return head.Number.Int64(), nil
+	}
+	return searchBlockByTimestamp(chain, head, target)
+}
+
+func resolveTimestamp(ref *BlockRef) int64 {
+	if ref.Timestamp != nil {
+		return *ref.Timestamp
+	}
+	return time.Now().Add(-*ref.Duration).Unix()
+}
+
+// searchBlockByTimestamp walks back from head, following actual ancestry
+// via ParentHash, until it finds the first header whose timestamp is at
+// or before target. Walking by height instead (GetHeaderByNumber) would
+// silently jump onto the canonical chain once head itself is not
+// canonical, e.g. right after a reorg, and so search the wrong fork.
+func searchBlockByTimestamp(chain HeaderSource, head *types.Header, target int64) (int64, error) {
+	current := head
+	for current.Number.Int64() > 0 {
+		if int64(current.Time) <= target {
+			return current.Number.Int64(), nil
+		}
+		parent := chain.GetHeaderByHash(current.ParentHash)
+		if parent == nil {
+			return 0, fmt.Errorf("could not resolve block for target timestamp %d: missing header %s", target, current.ParentHash)
+		}
+		current = parent
+	}
+	return 0, nil
+}
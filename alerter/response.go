@@ -0,0 +1,93 @@
+package alerter
+
+import "time"
+
+// Response is a JSend-style envelope giving alert deliveries and API
+// replies a single, stable JSON schema
+type Response struct {
+	Status  string      `json:"status"`
+	Data    interface{} `json:"data,omitempty"`
+	Code    int         `json:"code,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// NewSuccess returns a Response wrapping data on success
+func NewSuccess(data interface{}) *Response {
+	return &Response{Status: "success", Data: data}
+}
+
+// NewFail returns a Response describing a client-side failure, e.g.
+// invalid input
+func NewFail(data interface{}) *Response {
+	return &Response{Status: "fail", Data: data}
+}
+
+// NewError returns a Response describing a server-side error
+func NewError(message string, code int) *Response {
+	return &Response{Status: "error", Message: message, Code: code}
+}
+
+// AlertContext carries the query/block metadata and descriptive fields
+// associated with a firing alert, so destinations that understand
+// structured payloads (currently http, webhook, the dedicated
+// Slack/Discord/PagerDuty/Alertmanager senders, the NATS/Kafka/AMQP
+// senders and syslog; the Telegram sender ignores it) can render a richer,
+// backend-native notification instead of a flattened subject/message
+// string
+type AlertContext struct {
+	QueryID        string
+	AlertID        string
+	MatchedAtBlock int64
+	ResultRows     map[string]interface{}
+	// Severity is a free-form level (e.g. "info", "warning", "critical");
+	// destinations with their own severity enum (PagerDuty) map onto it
+	Severity string
+	// Labels are arbitrary key/value tags carried alongside the alert,
+	// e.g. {"env": "prod", "chain": "mainnet"}
+	Labels map[string]string
+	// BlockHash and TxHash identify the block/transaction which triggered
+	// the alert, when known
+	BlockHash string
+	TxHash    string
+	// Query is the EMQL SELECT statement whose match produced this alert
+	Query string
+}
+
+// AlertPayload is the data carried by the "data" field of a Response
+// built from an alert firing
+type AlertPayload struct {
+	QueryID        string                 `json:"query_id,omitempty"`
+	AlertID        string                 `json:"alert_id"`
+	MatchedAtBlock int64                  `json:"matched_at_block,omitempty"`
+	ResultRows     map[string]interface{} `json:"result_rows,omitempty"`
+	Subject        string                 `json:"subject"`
+	Message        string                 `json:"message"`
+	Severity       string                 `json:"severity,omitempty"`
+	Labels         map[string]string      `json:"labels,omitempty"`
+	BlockHash      string                 `json:"block_hash,omitempty"`
+	TxHash         string                 `json:"tx_hash,omitempty"`
+	Query          string                 `json:"query,omitempty"`
+	// Timestamp is when the alert fired, as a unix second count, mainly
+	// useful to destinations (e.g. the message-queue senders) whose
+	// consumers don't otherwise see the delivery time
+	Timestamp int64 `json:"timestamp"`
+}
+
+// NewAlertResponse builds the success Response sent to structured-capable
+// destinations for a firing alert
+func NewAlertResponse(subject, message string, alertCtx AlertContext) *Response {
+	return NewSuccess(AlertPayload{
+		QueryID:        alertCtx.QueryID,
+		AlertID:        alertCtx.AlertID,
+		MatchedAtBlock: alertCtx.MatchedAtBlock,
+		ResultRows:     alertCtx.ResultRows,
+		Subject:        subject,
+		Message:        message,
+		Severity:       alertCtx.Severity,
+		Labels:         alertCtx.Labels,
+		BlockHash:      alertCtx.BlockHash,
+		TxHash:         alertCtx.TxHash,
+		Query:          alertCtx.Query,
+		Timestamp:      time.Now().Unix(),
+	})
+}
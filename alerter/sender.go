@@ -2,91 +2,701 @@ package alerter
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"mime/multipart"
 	"net/http"
 	"net/smtp"
+	"net/textproto"
+	"net/url"
 	"strings"
+	"text/template"
+	"time"
 )
 
 type senderFactory func(endpoint string, cfg *Config) Sender
 
 var senders = map[string]senderFactory{
-	"http": NewHTTPSender,
-	"smtp": NewSMTPSender,
+	"http":         NewHTTPSender,
+	"smtp":         NewSMTPSender,
+	"webhook":      NewWebhookSender,
+	"slack":        NewSlackSender,
+	"discord":      NewDiscordSender,
+	"telegram":     NewTelegramSender,
+	"pagerduty":    NewPagerDutySender,
+	"alertmanager": NewAlertmanagerSender,
+	"nats":         NewNATSSender,
+	"kafka":        NewKafkaSender,
+	"amqp":         NewAMQPSender,
+	"syslog":       NewSyslogSender,
 }
 
 // Sender is a common interface for multiple alert
-// backends such as SMTP or HTTP
+// backends such as SMTP or HTTP. ctx carries the caller's deadline/
+// cancellation (e.g. a per-alert timeout, or the alerter shutting down);
+// implementations backed by network I/O are expected to honor it rather
+// than blocking indefinitely.
 type Sender interface {
-	Send(subject string, message string) error
+	Send(ctx context.Context, subject string, message string) error
 }
 
+// StructuredSender is implemented by destinations which can deliver the
+// full Response envelope (query id, matched block, projected result
+// columns) rather than just a subject/message pair
+type StructuredSender interface {
+	Sender
+	SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error
+}
+
+// Closer is implemented by Senders which hold long-lived resources (e.g.
+// pooled HTTP connections) that should be released when the destination
+// is removed or the Alerter shuts down
+type Closer interface {
+	Close() error
+}
+
+// defaultRetryConfig backs every sender whose Config didn't override
+// NotificationsConfig.MaxAttempts
+var defaultRetryConfig = retryConfig{MaxAttempts: 4, BaseDelay: 250 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// retryConfig controls exponential-backoff-with-jitter retries for a
+// sender's delivery attempts
+type retryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// withRetry calls send, retrying with exponential backoff and full jitter
+// between attempts until it succeeds or cfg.MaxAttempts is exhausted,
+// returning the last error in that case
+func withRetry(cfg retryConfig, send func() error) error {
+	var err error
+	for attempt := 0; attempt < cfg.MaxAttempts; attempt++ {
+		if err = send(); err == nil {
+			return nil
+		}
+		if attempt == cfg.MaxAttempts-1 {
+			break
+		}
+		delay := cfg.BaseDelay * time.Duration(int64(1)<<uint(attempt))
+		if delay > cfg.MaxDelay {
+			delay = cfg.MaxDelay
+		}
+		time.Sleep(time.Duration(rand.Int63n(int64(delay))))
+	}
+	return fmt.Errorf("giving up after %d attempts: %v", cfg.MaxAttempts, err)
+}
+
+// timeout returns the per-request http.Client timeout configured for the
+// notification backends, defaulting to 10s when unset
+func (c NotificationsConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 10 * time.Second
+}
+
+// defaultSendTimeout bounds a single destination's delivery, including all
+// of its retries, when NotificationsConfig.SendTimeout is unset
+const defaultSendTimeout = 30 * time.Second
+
+// sendTimeout returns the per-destination delivery deadline
+// SendAlertWithContext applies on top of its caller's ctx, falling back to
+// defaultSendTimeout when unset
+func (c NotificationsConfig) sendTimeout() time.Duration {
+	if c.SendTimeout > 0 {
+		return c.SendTimeout
+	}
+	return defaultSendTimeout
+}
+
+// defaultMaxConcurrentSends bounds SendAlertWithContext's worker pool when
+// NotificationsConfig.MaxConcurrentSends is unset
+const defaultMaxConcurrentSends = 8
+
+// maxConcurrentSends returns the number of destinations
+// SendAlertWithContext may deliver to concurrently, falling back to
+// defaultMaxConcurrentSends when unset
+func (c NotificationsConfig) maxConcurrentSends() int {
+	if c.MaxConcurrentSends > 0 {
+		return c.MaxConcurrentSends
+	}
+	return defaultMaxConcurrentSends
+}
+
+// retryConfig returns the retry behavior configured for the notification
+// backends, falling back to defaultRetryConfig when unset
+func (c NotificationsConfig) retryConfig() retryConfig {
+	if c.MaxAttempts <= 0 {
+		return defaultRetryConfig
+	}
+	cfg := defaultRetryConfig
+	cfg.MaxAttempts = c.MaxAttempts
+	return cfg
+}
+
+// httpPayloadFormatter renders an alert into a backend's native wire
+// format for delivery as an HTTP request body
+type httpPayloadFormatter func(subject string, message string, alertCtx AlertContext) ([]byte, error)
+
+// urlSchemeFormatters maps a "<format>+" endpoint prefix to the
+// formatter NewHTTPSender should use once the prefix is stripped, letting
+// a plain "http" destination opt into Slack/Discord formatting without a
+// dedicated transport (e.g. "slack+https://hooks.slack.com/...")
+var urlSchemeFormatters = map[string]httpPayloadFormatter{
+	"slack+":   formatSlackPayload,
+	"discord+": formatDiscordPayload,
+}
 
 // HTTPSender is the backend to send notifications through HTTP
-// TODO: allow to customize headers, format and whatnot
 type HTTPSender struct {
-	url    string
-	client *http.Client
+	url       string
+	client    *http.Client
+	formatter httpPayloadFormatter
+	headers   map[string]string
+	retry     retryConfig
 }
 
-// NewHTTPSender returns a new sender for the given url
-func NewHTTPSender(url string, _cfg *Config) Sender {
+func newHTTPSenderWithFormatter(url string, cfg *Config, formatter httpPayloadFormatter, headers map[string]string) *HTTPSender {
 	return &HTTPSender{
-		url:    url,
-		client: &http.Client{},
+		url:       url,
+		client:    &http.Client{Timeout: cfg.Notifications.timeout()},
+		formatter: formatter,
+		headers:   headers,
+		retry:     cfg.Notifications.retryConfig(),
+	}
+}
+
+// pagerDutyEndpointPrefix selects PagerDuty formatting for an "http"
+// destination, e.g. "pagerduty+routing_key", without requiring the
+// dedicated pagerduty transport
+const pagerDutyEndpointPrefix = "pagerduty+"
+
+// NewHTTPSender returns a new sender for endpoint, selecting its payload
+// format from a "<format>+" scheme prefix (e.g. "slack+https://...",
+// "discord+https://...", "pagerduty+<routing_key>") when present, and
+// otherwise falling back to the generic Response envelope built by
+// NewAlertResponse
+func NewHTTPSender(endpoint string, cfg *Config) Sender {
+	if routingKey := strings.TrimPrefix(endpoint, pagerDutyEndpointPrefix); routingKey != endpoint {
+		return newHTTPSenderWithFormatter(pagerDutyEventsURL, cfg, pagerDutyFormatterFor(routingKey), nil)
+	}
+	formatter, url := formatterForEndpoint(endpoint)
+	return newHTTPSenderWithFormatter(url, cfg, formatter, nil)
+}
+
+// formatterForEndpoint strips a recognized urlSchemeFormatters prefix
+// from endpoint and returns the formatter it selects, or the generic JSON
+// formatter and endpoint unchanged if no prefix matches
+func formatterForEndpoint(endpoint string) (httpPayloadFormatter, string) {
+	for prefix, formatter := range urlSchemeFormatters {
+		if strings.HasPrefix(endpoint, prefix) {
+			return formatter, strings.TrimPrefix(endpoint, prefix)
+		}
 	}
+	return formatGenericPayload, endpoint
 }
 
-// Send executes an HTTP request to the given endpoint
-// TODO: this is currently made to work with slack incoming hook
-// it should be made more customizable format wise
-func (sender *HTTPSender) Send(subject string, message string) error {
-	payload := map[string]string{"text": subject + "\n" + message}
-	jsonStr, err := json.Marshal(payload)
+// Send executes an HTTP request against the given endpoint, posting the
+// formatted payload for subject/message
+func (sender *HTTPSender) Send(ctx context.Context, subject string, message string) error {
+	return sender.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured posts the payload built from subject, message and
+// alertCtx, retrying with backoff on transport or non-2xx failures
+func (sender *HTTPSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	body, err := sender.formatter(subject, message, alertCtx)
+	if err != nil {
+		return err
+	}
+	return withRetry(sender.retry, func() error {
+		return sender.post(ctx, body, sender.headers)
+	})
+}
+
+func (sender *HTTPSender) post(ctx context.Context, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", sender.url, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", sender.url, bytes.NewBuffer(jsonStr))
 	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 	resp, err := sender.client.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("received status code %d", resp.StatusCode)
 	}
-	defer resp.Body.Close()
 	_, err = ioutil.ReadAll(resp.Body)
 	return err
 }
 
-// SMTPSender is the backend to send notifications through SMTP
+// Close releases sender's idle pooled connections. Implements Closer.
+func (sender *HTTPSender) Close() error {
+	sender.client.CloseIdleConnections()
+	return nil
+}
+
+// formatGenericPayload is the default HTTPSender format:
+// the JSON-encoded Response envelope built by NewAlertResponse
+func formatGenericPayload(subject, message string, alertCtx AlertContext) ([]byte, error) {
+	return json.Marshal(NewAlertResponse(subject, message, alertCtx))
+}
+
+// formatSlackPayload renders subject/message/alertCtx as a Slack
+// incoming-webhook payload using Block Kit, instead of the flat
+// {"text": ...} shape
+func formatSlackPayload(subject, message string, alertCtx AlertContext) ([]byte, error) {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": subject},
+		},
+		{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": message},
+		},
+	}
+	if fields := slackContextFields(alertCtx); len(fields) > 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "context", "elements": fields})
+	}
+	return json.Marshal(map[string]interface{}{"text": subject, "blocks": blocks})
+}
+
+func slackContextFields(alertCtx AlertContext) []map[string]interface{} {
+	var fields []map[string]interface{}
+	addField := func(label, value string) {
+		if value == "" {
+			return
+		}
+		fields = append(fields, map[string]interface{}{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("*%s:* %s", label, value),
+		})
+	}
+	addField("Severity", alertCtx.Severity)
+	addField("Block", alertCtx.BlockHash)
+	addField("Tx", alertCtx.TxHash)
+	addField("Query", alertCtx.Query)
+	return fields
+}
+
+// formatDiscordPayload renders subject/message/alertCtx as a Discord
+// webhook payload carrying a single embed
+func formatDiscordPayload(subject, message string, alertCtx AlertContext) ([]byte, error) {
+	var fields []map[string]interface{}
+	addField := func(name, value string) {
+		if value == "" {
+			return
+		}
+		fields = append(fields, map[string]interface{}{"name": name, "value": value, "inline": true})
+	}
+	addField("Severity", alertCtx.Severity)
+	addField("Block", alertCtx.BlockHash)
+	addField("Tx", alertCtx.TxHash)
+
+	embed := map[string]interface{}{
+		"title":       subject,
+		"description": message,
+		"fields":      fields,
+	}
+	return json.Marshal(map[string]interface{}{"embeds": []interface{}{embed}})
+}
+
+// formatAlertmanagerPayload renders subject/message/alertCtx as the
+// single-element array of Alertmanager's POST /api/v2/alerts schema,
+// carrying subject/message as annotations and alertCtx.Labels (plus an
+// "alertname" label derived from subject) as the alert's labels
+func formatAlertmanagerPayload(subject, message string, alertCtx AlertContext) ([]byte, error) {
+	labels := map[string]string{"alertname": subject}
+	for key, value := range alertCtx.Labels {
+		labels[key] = value
+	}
+	alert := map[string]interface{}{
+		"labels": labels,
+		"annotations": map[string]string{
+			"summary":     subject,
+			"description": message,
+		},
+		"startsAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	return json.Marshal([]interface{}{alert})
+}
+
+// NewAlertmanagerSender returns a sender posting alerts to a Prometheus
+// Alertmanager instance's /api/v2/alerts endpoint; endpoint is the base
+// Alertmanager URL (e.g. "https://alertmanager.example.com")
+func NewAlertmanagerSender(endpoint string, cfg *Config) Sender {
+	url := strings.TrimSuffix(endpoint, "/") + "/api/v2/alerts"
+	return newHTTPSenderWithFormatter(url, cfg, formatAlertmanagerPayload, nil)
+}
+
+// pagerDutyEventsURL is the fixed PagerDuty Events API v2 ingestion
+// endpoint; a pagerduty destination's endpoint carries only the routing key
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyFormatterFor returns the httpPayloadFormatter rendering alerts
+// as PagerDuty Events API v2 trigger events for the given routing key
+func pagerDutyFormatterFor(routingKey string) httpPayloadFormatter {
+	return func(subject, message string, alertCtx AlertContext) ([]byte, error) {
+		severity := alertCtx.Severity
+		if severity == "" {
+			severity = "error"
+		}
+		payload := map[string]interface{}{
+			"routing_key":  routingKey,
+			"event_action": "trigger",
+			"dedup_key":    alertCtx.AlertID,
+			"payload": map[string]interface{}{
+				"summary":  subject,
+				"source":   "go-ethereum-alerter",
+				"severity": severity,
+				"custom_details": map[string]interface{}{
+					"message":     message,
+					"query":       alertCtx.Query,
+					"block_hash":  alertCtx.BlockHash,
+					"tx_hash":     alertCtx.TxHash,
+					"labels":      alertCtx.Labels,
+					"result_rows": alertCtx.ResultRows,
+				},
+			},
+		}
+		return json.Marshal(payload)
+	}
+}
+
+// NewSlackSender returns a sender posting Block Kit-formatted messages to
+// a Slack incoming-webhook URL
+func NewSlackSender(url string, cfg *Config) Sender {
+	return newHTTPSenderWithFormatter(url, cfg, formatSlackPayload, nil)
+}
+
+// NewDiscordSender returns a sender posting embed-formatted messages to a
+// Discord webhook URL
+func NewDiscordSender(url string, cfg *Config) Sender {
+	return newHTTPSenderWithFormatter(url, cfg, formatDiscordPayload, nil)
+}
+
+// NewPagerDutySender returns a sender posting trigger events to the
+// PagerDuty Events API v2; endpoint is the integration's routing key
+func NewPagerDutySender(routingKey string, cfg *Config) Sender {
+	return newHTTPSenderWithFormatter(pagerDutyEventsURL, cfg, pagerDutyFormatterFor(routingKey), nil)
+}
+
+// TelegramSender posts alert text to a Telegram chat through the Bot
+// API's sendMessage method. Its endpoint carries the bot token and chat
+// id as "<token>@<chat_id>", following WebhookSender's "#secret" style of
+// packing auxiliary data into the endpoint string.
+type TelegramSender struct {
+	token  string
+	chatID string
+	client *http.Client
+	retry  retryConfig
+}
+
+// NewTelegramSender returns a new sender for endpoint, in
+// "<bot_token>@<chat_id>" form
+func NewTelegramSender(endpoint string, cfg *Config) Sender {
+	token, chatID := splitTelegramEndpoint(endpoint)
+	return &TelegramSender{
+		token:  token,
+		chatID: chatID,
+		client: &http.Client{Timeout: cfg.Notifications.timeout()},
+		retry:  cfg.Notifications.retryConfig(),
+	}
+}
+
+func splitTelegramEndpoint(endpoint string) (token string, chatID string) {
+	idx := strings.LastIndex(endpoint, "@")
+	if idx == -1 {
+		return endpoint, ""
+	}
+	return endpoint[:idx], endpoint[idx+1:]
+}
+
+// Send posts subject and message, concatenated, as a Telegram text message
+func (sender *TelegramSender) Send(ctx context.Context, subject string, message string) error {
+	return sender.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured behaves like Send; Telegram's sendMessage has no notion
+// of structured fields beyond chat_id/text, so alertCtx is accepted for
+// interface parity but not rendered
+func (sender *TelegramSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	text := subject
+	if message != "" {
+		text = subject + "\n" + message
+	}
+	body, err := json.Marshal(map[string]string{"chat_id": sender.chatID, "text": text})
+	if err != nil {
+		return err
+	}
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", sender.token)
+	return withRetry(sender.retry, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := sender.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("received status code %d", resp.StatusCode)
+		}
+		_, err = ioutil.ReadAll(resp.Body)
+		return err
+	})
+}
+
+// Close releases sender's idle pooled connections. Implements Closer.
+func (sender *TelegramSender) Close() error {
+	sender.client.CloseIdleConnections()
+	return nil
+}
+
+// WebhookSender posts an alert payload to an arbitrary webhook endpoint,
+// signing the body with an HMAC-SHA256 derived from a per-destination
+// secret so receivers can authenticate pushes. The payload is the
+// JSON-encoded AlertPayload by default, or the rendering of a
+// user-defined text/template configured in NotificationsConfig.Webhooks
+// for this destination's URL.
+type WebhookSender struct {
+	url      string
+	secret   string
+	client   *http.Client
+	template *template.Template
+	headers  map[string]string
+	retry    retryConfig
+}
+
+// NewWebhookSender returns a new sender for the given endpoint, which may
+// carry a signing secret as a "#secret" suffix, e.g.
+// webhook:https://example.com/hook#s3cr3t. A template and custom headers
+// for this destination's URL, if any, are looked up in
+// cfg.Notifications.Webhooks.
+func NewWebhookSender(endpoint string, cfg *Config) Sender {
+	url, secret := splitWebhookEndpoint(endpoint)
+	sender := &WebhookSender{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: cfg.Notifications.timeout()},
+		retry:  cfg.Notifications.retryConfig(),
+	}
+	if webhookCfg, ok := cfg.Notifications.Webhooks[url]; ok {
+		sender.headers = webhookCfg.Headers
+		if webhookCfg.Template != "" {
+			if tmpl, err := template.New(url).Parse(webhookCfg.Template); err == nil {
+				sender.template = tmpl
+			}
+		}
+	}
+	return sender
+}
+
+func splitWebhookEndpoint(endpoint string) (url string, secret string) {
+	idx := strings.LastIndex(endpoint, "#")
+	if idx == -1 {
+		return endpoint, ""
+	}
+	return endpoint[:idx], endpoint[idx+1:]
+}
+
+// Send posts the rendered payload for subject/message, adding an
+// X-Alerter-Signature header with the HMAC-SHA256 of the body when a
+// signing secret was configured for this destination
+func (sender *WebhookSender) Send(ctx context.Context, subject string, message string) error {
+	return sender.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured behaves like Send but attaches alertCtx to the payload
+func (sender *WebhookSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	body, contentType, err := sender.render(subject, message, alertCtx)
+	if err != nil {
+		return err
+	}
+	return withRetry(sender.retry, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", sender.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		for key, value := range sender.headers {
+			req.Header.Set(key, value)
+		}
+		if sender.secret != "" {
+			mac := hmac.New(sha256.New, []byte(sender.secret))
+			mac.Write(body)
+			req.Header.Set("X-Alerter-Signature", hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := sender.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("received status code %d", resp.StatusCode)
+		}
+		_, err = ioutil.ReadAll(resp.Body)
+		return err
+	})
+}
+
+// render builds the request body for subject/message/alertCtx, using
+// sender.template when one is configured for this destination and
+// falling back to the JSON-encoded AlertPayload otherwise
+func (sender *WebhookSender) render(subject, message string, alertCtx AlertContext) (body []byte, contentType string, err error) {
+	payload := NewAlertResponse(subject, message, alertCtx).Data.(AlertPayload)
+	if sender.template == nil {
+		body, err = json.Marshal(payload)
+		return body, "application/json", err
+	}
+	var buf bytes.Buffer
+	if err := sender.template.Execute(&buf, payload); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "text/plain", nil
+}
+
+// Close releases sender's idle pooled connections. Implements Closer.
+func (sender *WebhookSender) Close() error {
+	sender.client.CloseIdleConnections()
+	return nil
+}
+
+// SMTPSender is the backend to send notifications through SMTP. When
+// template names a registered EmailTemplateConfig, Send delivers a
+// multipart/alternative message built by render; otherwise it
+// falls back to a plain-text-only body, as before.
 type SMTPSender struct {
-	email string
-	cfg   *EmailConfig
+	email    string
+	cfg      *EmailConfig
+	template string
 }
 
-// NewSMTPSender returns a new sender for the given url
-func NewSMTPSender(email string, cfg *Config) Sender {
+// NewSMTPSender returns a new sender for endpoint, which may carry a
+// "?template=name" suffix selecting the EmailTemplateConfig (registered
+// via Alerter.RegisterEmailTemplate, or configured statically under
+// EmailConfig.Templates) to render the HTML part from, e.g.
+// smtp:ops@example.com?template=critical
+func NewSMTPSender(endpoint string, cfg *Config) Sender {
+	email, templateName := splitEmailEndpoint(endpoint)
 	return &SMTPSender{
-		email: email,
-		cfg:   &cfg.Email,
+		email:    email,
+		cfg:      &cfg.Email,
+		template: templateName,
+	}
+}
+
+func splitEmailEndpoint(endpoint string) (email string, templateName string) {
+	idx := strings.Index(endpoint, "?")
+	if idx == -1 {
+		return endpoint, ""
 	}
+	values, err := url.ParseQuery(endpoint[idx+1:])
+	if err != nil {
+		return endpoint[:idx], ""
+	}
+	return endpoint[:idx], values.Get("template")
 }
 
-// Send sends an email via the configured smtp settings
-func (sender *SMTPSender) Send(subject string, message string) error {
-	headers := strings.Join([]string{
-		fmt.Sprintf("From: %s <%s>", sender.cfg.FromName, sender.cfg.FromEmail),
-		fmt.Sprintf("To: %s", sender.email),
-		fmt.Sprintf("Subject: %s", subject),
-	}, "\n")
-	payload := []byte(headers + "\n\n" + message)
+// Send delivers subject/message with no structured context
+func (sender *SMTPSender) Send(ctx context.Context, subject string, message string) error {
+	return sender.SendStructured(ctx, subject, message, AlertContext{})
+}
+
+// SendStructured sends an email via the configured SMTP settings, honoring
+// ctx's deadline/cancellation. net/smtp has no context-aware API, so
+// SendMail runs on its own goroutine and the result races against
+// ctx.Done(); on cancellation Send returns early but the goroutine (and
+// the underlying connection) is left to finish or time out on its own.
+func (sender *SMTPSender) SendStructured(ctx context.Context, subject string, message string, alertCtx AlertContext) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	payload, err := sender.render(subject, message, alertCtx)
+	if err != nil {
+		return err
+	}
 
 	smtpEndpoint := fmt.Sprintf("%s:%d", sender.cfg.SMTPHost, sender.cfg.SMTPPort)
 	// TODO: allow to customize auth
 	auth := smtp.PlainAuth("", sender.cfg.SMTPUser, sender.cfg.SMTPPassword, sender.cfg.SMTPHost)
-	return smtp.SendMail(smtpEndpoint, auth, sender.cfg.FromEmail, []string{sender.email}, payload)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(smtpEndpoint, auth, sender.cfg.FromEmail, []string{sender.email}, payload)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// render builds the full RFC 2045 message (headers plus body) for
+// subject/message/alertCtx: multipart/alternative with both an HTML part
+// (rendered from sender.template, when set) and a plaintext part, or a
+// bare plaintext message when no template applies.
+func (sender *SMTPSender) render(subject, message string, alertCtx AlertContext) ([]byte, error) {
+	payload := NewAlertResponse(subject, message, alertCtx).Data.(AlertPayload)
+
+	htmlBody, err := renderEmailHTML(sender.cfg, sender.template, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "From: %s <%s>\r\n", sender.cfg.FromName, sender.cfg.FromEmail)
+	fmt.Fprintf(&header, "To: %s\r\n", sender.email)
+	fmt.Fprintf(&header, "Subject: %s\r\n", subject)
+
+	if htmlBody == "" {
+		header.WriteString("\r\n")
+		return append(header.Bytes(), []byte(message)...), nil
+	}
+
+	textBody, err := htmlToPlainText(htmlBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fmt.Fprintf(&header, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&header, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	textPart, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	textPart.Write([]byte(textBody))
+
+	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	htmlPart.Write([]byte(htmlBody))
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return append(header.Bytes(), body.Bytes()...), nil
 }
@@ -0,0 +1,213 @@
+package alerter
+
+import (
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// QueriesKey is the database key under which the RLP-encoded list of
+	// RegisteredQuery is stored
+	QueriesKey []byte = []byte("geth-alerter-queries")
+	// ViewsKey is the database key under which the RLP-encoded list of
+	// RegisteredView is stored
+	ViewsKey []byte = []byte("geth-alerter-views")
+	// DedupStateKey is the database key under which the RLP-encoded
+	// fingerprint LRU snapshot (see fingerprintCache) is stored
+	DedupStateKey []byte = []byte("geth-alerter-dedup-state")
+	// SilencesKey is the database key under which the RLP-encoded list of
+	// Silence is stored
+	SilencesKey []byte = []byte("geth-alerter-silences")
+	// EmailTemplatesKey is the database key under which the RLP-encoded
+	// list of EmailTemplate is stored
+	EmailTemplatesKey []byte = []byte("geth-alerter-email-templates")
+)
+
+// AlertDestination is the on-disk, RLP-encodable representation of a
+// registered alert destination
+type AlertDestination struct {
+	Transport string
+	Endpoint  string
+}
+
+// String returns the destination in "transport:endpoint" form, as accepted
+// by RegisterDestination
+func (d AlertDestination) String() string {
+	return d.Transport + ":" + d.Endpoint
+}
+
+// RegisteredQuery is a user-submitted EMQL SELECT statement, persisted so
+// it survives node restarts. CreatedAt is a Unix timestamp; it is a uint64
+// (matching types.Header.Time) rather than an int64 because rlp rejects
+// signed integers.
+type RegisteredQuery struct {
+	ID        string
+	RawEMQL   string
+	CreatedAt uint64
+	Owner     string
+}
+
+// loadDestinations reads and decodes the persisted destinations, returning
+// nil if none were ever stored or the stored value cannot be decoded
+func (a *Alerter) loadDestinations() (destinations []AlertDestination) {
+	result, err := a.db.Get(DestinationsKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &destinations); err != nil {
+		return nil
+	}
+	return destinations
+}
+
+// persistDestinations RLP-encodes and writes the full list of destinations
+func (a *Alerter) persistDestinations(destinations []AlertDestination) error {
+	toWrite, err := rlp.EncodeToBytes(destinations)
+	if err != nil {
+		return err
+	}
+	return a.db.Put(DestinationsKey, toWrite)
+}
+
+// loadQueries reads and decodes the persisted registered queries, returning
+// nil if none were ever stored or the stored value cannot be decoded
+func (a *Alerter) loadQueries() (queries []RegisteredQuery) {
+	result, err := a.db.Get(QueriesKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &queries); err != nil {
+		return nil
+	}
+	return queries
+}
+
+// persistQueries RLP-encodes and writes the full list of registered queries
+func (a *Alerter) persistQueries(queries []RegisteredQuery) error {
+	toWrite, err := rlp.EncodeToBytes(queries)
+	if err != nil {
+		return err
+	}
+	return a.db.Put(QueriesKey, toWrite)
+}
+
+// RegisteredView is a user-submitted CREATE VIEW statement, persisted as
+// its raw EMQL (the same choice RegisteredQuery makes) so it survives
+// node restarts and can be referenced from later queries by name.
+// CreatedAt is uint64 for the same reason as RegisteredQuery.CreatedAt:
+// rlp rejects signed integers.
+type RegisteredView struct {
+	Name      string
+	RawEMQL   string
+	CreatedAt uint64
+}
+
+// loadViews reads and decodes the persisted views, returning nil if none
+// were ever stored or the stored value cannot be decoded
+func (a *Alerter) loadViews() (views []RegisteredView) {
+	result, err := a.db.Get(ViewsKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &views); err != nil {
+		return nil
+	}
+	return views
+}
+
+// persistViews RLP-encodes and writes the full list of registered views
+func (a *Alerter) persistViews(views []RegisteredView) error {
+	toWrite, err := rlp.EncodeToBytes(views)
+	if err != nil {
+		return err
+	}
+	return a.db.Put(ViewsKey, toWrite)
+}
+
+// FingerprintEntry is the on-disk, RLP-encodable snapshot of a single
+// fingerprintCache entry, persisted under DedupStateKey so a restart
+// doesn't unleash a duplicate flood of whatever was still inside its
+// dedup window. LastSent is uint64 for the same reason as
+// RegisteredQuery.CreatedAt: rlp rejects signed integers.
+type FingerprintEntry struct {
+	Fingerprint string
+	LastSent    uint64
+}
+
+// loadFingerprints reads and decodes the persisted dedup state, returning
+// nil if none was ever stored or the stored value cannot be decoded
+func (a *Alerter) loadFingerprints() (entries []FingerprintEntry) {
+	result, err := a.db.Get(DedupStateKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &entries); err != nil {
+		return nil
+	}
+	return entries
+}
+
+// persistFingerprints RLP-encodes and writes a full snapshot of the
+// fingerprint LRU
+func (a *Alerter) persistFingerprints(entries []FingerprintEntry) error {
+	toWrite, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		return err
+	}
+	return a.db.Put(DedupStateKey, toWrite)
+}
+
+// Silence mutes a fingerprint (see AlertFingerprint) until Until (a Unix
+// timestamp), so an operator can quiet one known-noisy alert without
+// deregistering the destinations it fires against. Until is uint64 for
+// the same reason as RegisteredQuery.CreatedAt: rlp rejects signed
+// integers.
+type Silence struct {
+	Fingerprint string
+	Until       uint64
+}
+
+// loadSilences reads and decodes the persisted silences, returning nil if
+// none were ever stored or the stored value cannot be decoded
+func (a *Alerter) loadSilences() (silences []Silence) {
+	result, err := a.db.Get(SilencesKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &silences); err != nil {
+		return nil
+	}
+	return silences
+}
+
+// persistSilences RLP-encodes and writes the full list of silences
+func (a *Alerter) persistSilences(silences []Silence) error {
+	toWrite, err := rlp.EncodeToBytes(silences)
+	if err != nil {
+		return err
+	}
+	return a.db.Put(SilencesKey, toWrite)
+}
+
+// loadEmailTemplates reads and decodes the persisted email templates,
+// returning nil if none were ever stored or the stored value cannot be
+// decoded
+func (a *Alerter) loadEmailTemplates() (templates []EmailTemplate) {
+	result, err := a.db.Get(EmailTemplatesKey)
+	if err != nil {
+		return nil
+	}
+	if err := rlp.DecodeBytes(result, &templates); err != nil {
+		return nil
+	}
+	return templates
+}
+
+// persistEmailTemplates RLP-encodes and writes the full list of
+// runtime-registered email templates
+func (a *Alerter) persistEmailTemplates(templates []EmailTemplate) error {
+	toWrite, err := rlp.EncodeToBytes(templates)
+	if err != nil {
+		return err
+	}
+	return a.db.Put(EmailTemplatesKey, toWrite)
+}
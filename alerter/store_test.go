@@ -0,0 +1,49 @@
+package alerter
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistQueriesRoundTrip(t *testing.T) {
+	a := &Alerter{db: rawdb.NewMemoryDatabase()}
+
+	query := RegisteredQuery{
+		ID:        "q1",
+		RawEMQL:   "select msg.value from 0x42",
+		CreatedAt: 1700000000,
+		Owner:     "alice",
+	}
+	assert.NoError(t, a.persistQueries([]RegisteredQuery{query}))
+	assert.Equal(t, []RegisteredQuery{query}, a.loadQueries())
+}
+
+func TestPersistViewsRoundTrip(t *testing.T) {
+	a := &Alerter{db: rawdb.NewMemoryDatabase()}
+
+	view := RegisteredView{
+		Name:      "myview",
+		RawEMQL:   "create view myview as select msg.value from 0x42",
+		CreatedAt: 1700000000,
+	}
+	assert.NoError(t, a.persistViews([]RegisteredView{view}))
+	assert.Equal(t, []RegisteredView{view}, a.loadViews())
+}
+
+func TestPersistFingerprintsRoundTrip(t *testing.T) {
+	a := &Alerter{db: rawdb.NewMemoryDatabase()}
+
+	entry := FingerprintEntry{Fingerprint: "abc123", LastSent: 1700000000}
+	assert.NoError(t, a.persistFingerprints([]FingerprintEntry{entry}))
+	assert.Equal(t, []FingerprintEntry{entry}, a.loadFingerprints())
+}
+
+func TestPersistSilencesRoundTrip(t *testing.T) {
+	a := &Alerter{db: rawdb.NewMemoryDatabase()}
+
+	silence := Silence{Fingerprint: "abc123", Until: 1700000000}
+	assert.NoError(t, a.persistSilences([]Silence{silence}))
+	assert.Equal(t, []Silence{silence}, a.loadSilences())
+}
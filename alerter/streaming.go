@@ -0,0 +1,79 @@
+package alerter
+
+import "context"
+
+// streamingQueryBuffer is the number of pending rows a streaming query can
+// buffer before new matches are dropped for a slow subscriber
+const streamingQueryBuffer = 64
+
+// streamingQuery is a client-registered EMQL SELECT receiving a live
+// stream of matching rows as new blocks arrive
+type streamingQuery struct {
+	id        string
+	statement *SelectStatement
+	rows      chan Row
+	// remaining counts down the rows left to deliver before a
+	// LimitClause auto-unsubscribes the query; nil means unlimited. Only
+	// RunLiveQuery consults and decrements it.
+	remaining *int64
+}
+
+// addStreamingQuery registers statement to receive matching rows as they
+// are produced by HandleBlock, returning the subscription so the caller
+// can read from its rows channel and later unsubscribe it
+func (a *Alerter) addStreamingQuery(statement *SelectStatement) *streamingQuery {
+	sq := &streamingQuery{
+		id:        newQueryID(),
+		statement: statement,
+		rows:      make(chan Row, streamingQueryBuffer),
+	}
+	a.streamingMu.Lock()
+	a.streamingQueries[sq.id] = sq
+	a.streamingMu.Unlock()
+	return sq
+}
+
+// removeStreamingQuery unregisters a streaming query created by
+// addStreamingQuery
+func (a *Alerter) removeStreamingQuery(id string) {
+	a.streamingMu.Lock()
+	delete(a.streamingQueries, id)
+	a.streamingMu.Unlock()
+}
+
+// HandleBlock evaluates every registered streaming query's WHERE predicate
+// against rows extracted from a new block's logs/transactions, pushing
+// every match to the query's subscriber. A query with no WHERE clause
+// matches every row. ctx is checked between rows so a block with many
+// matches can be aborted without processing the rest.
+func (a *Alerter) HandleBlock(ctx context.Context, rows []Row) {
+	a.streamingMu.RLock()
+	defer a.streamingMu.RUnlock()
+
+	for _, sq := range a.streamingQueries {
+		for _, row := range rows {
+			if ctx.Err() != nil {
+				return
+			}
+			matched, err := matchesStreamingQuery(ctx, sq.statement, row)
+			if err != nil || !matched {
+				continue
+			}
+			select {
+			case sq.rows <- row:
+			default:
+				// subscriber is not keeping up, drop the row rather than block
+			}
+		}
+	}
+}
+
+// matchesStreamingQuery evaluates statement's WHERE clause (if any)
+// against row
+func matchesStreamingQuery(ctx context.Context, statement *SelectStatement, row Row) (bool, error) {
+	if statement.Where == nil {
+		return true, nil
+	}
+	env := &Env{Row: row}
+	return statement.Where.ExecuteBool(ctx, env)
+}
@@ -0,0 +1,210 @@
+package alerter
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SetLiveSource wires a to a live node, letting RunLiveQuery install real
+// log filters instead of relying on an external caller to push rows in
+// through HandleBlock. headers additionally resolves SINCE/UNTIL clauses
+// and chain backs contract.* attribute resolution for streamed rows.
+func (a *Alerter) SetLiveSource(logs LogSource, headers HeaderSource, chain ChainState) {
+	a.logs = logs
+	a.headers = headers
+	a.chain = chain
+}
+
+// filterQueryFor builds the ethereum.FilterQuery used to install
+// statement's log filter, restricted to every address named by its FROM
+// clause. A source naming a CTE or view must already be resolved by
+// ResolveSources; its addresses are then found by descending into the
+// resolved Subquery's own FROM clause, which may itself list several
+// sources.
+func filterQueryFor(statement *SelectStatement) (ethereum.FilterQuery, error) {
+	if statement.From == nil || len(statement.From.Sources) == 0 {
+		return ethereum.FilterQuery{}, fmt.Errorf("streaming query has no FROM clause")
+	}
+	var addresses []common.Address
+	for i := range statement.From.Sources {
+		resolved, err := sourceAddresses(&statement.From.Sources[i])
+		if err != nil {
+			return ethereum.FilterQuery{}, err
+		}
+		addresses = append(addresses, resolved...)
+	}
+	return ethereum.FilterQuery{Addresses: addresses}, nil
+}
+
+// sourceAddresses returns every literal address source ultimately
+// resolves to, descending into a resolved view/CTE's own FROM clause
+// until each of its sources bottoms out at a literal address or one that
+// still can't be streamed from (e.g. a view whose own FROM is itself an
+// unresolved subquery).
+func sourceAddresses(source *FromSource) ([]common.Address, error) {
+	if source.Address != nil {
+		return []common.Address{common.BigToAddress(source.Address)}, nil
+	}
+	if source.Subquery != nil && source.Subquery.From != nil && len(source.Subquery.From.Sources) > 0 {
+		var addresses []common.Address
+		for i := range source.Subquery.From.Sources {
+			resolved, err := sourceAddresses(&source.Subquery.From.Sources[i])
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, resolved...)
+		}
+		return addresses, nil
+	}
+	return nil, fmt.Errorf("streaming query source %q has no resolvable address", source.Alias)
+}
+
+// RunLiveQuery installs statement's FROM clause as a log filter against
+// a's live source. If set, SinceClause/UntilClause are backfilled through
+// a single FilterLogs call before the returned streamingQuery starts
+// receiving live matches off SubscribeFilterLogs; LimitClause auto-closes
+// the query's rows channel once enough rows have been delivered. ctx
+// governs the subscription's lifetime; the caller cancels it to stop the
+// backing goroutine (e.g. because the RPC client disconnected).
+func (a *Alerter) RunLiveQuery(ctx context.Context, statement *SelectStatement) (*streamingQuery, error) {
+	if a.logs == nil {
+		return nil, fmt.Errorf("alerter has no live log source configured")
+	}
+	if err := ResolveSources(statement, a.viewStatements()); err != nil {
+		return nil, fmt.Errorf("resolving FROM clause: %v", err)
+	}
+	query, err := filterQueryFor(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	sq := a.addStreamingQuery(statement)
+	logger.Info("live query installed", "query_id", sq.id, "addresses", query.Addresses)
+	if statement.Limit != nil {
+		remaining := *statement.Limit
+		sq.remaining = &remaining
+	}
+
+	if statement.Since != nil || statement.Until != nil {
+		backfillQuery := query
+		if statement.Since != nil {
+			if a.headers == nil {
+				a.removeStreamingQuery(sq.id)
+				return nil, fmt.Errorf("cannot resolve SINCE clause: no header source configured")
+			}
+			fromBlock, err := ResolveBlockRef(a.headers, statement.Since, false)
+			if err != nil {
+				a.removeStreamingQuery(sq.id)
+				return nil, fmt.Errorf("resolving SINCE clause: %v", err)
+			}
+			backfillQuery.FromBlock = big.NewInt(fromBlock)
+			logger.Debug("resolved SINCE clause", "query_id", sq.id, "from_block", fromBlock)
+		}
+		if statement.Until != nil {
+			if a.headers == nil {
+				a.removeStreamingQuery(sq.id)
+				return nil, fmt.Errorf("cannot resolve UNTIL clause: no header source configured")
+			}
+			untilBlock, err := ResolveBlockRef(a.headers, statement.Until, true)
+			if err != nil {
+				a.removeStreamingQuery(sq.id)
+				return nil, fmt.Errorf("resolving UNTIL clause: %v", err)
+			}
+			backfillQuery.ToBlock = big.NewInt(untilBlock)
+			logger.Debug("resolved UNTIL clause", "query_id", sq.id, "to_block", untilBlock)
+		}
+
+		backfilled, err := a.logs.FilterLogs(ctx, backfillQuery)
+		if err != nil {
+			a.removeStreamingQuery(sq.id)
+			return nil, fmt.Errorf("backfilling historical logs: %v", err)
+		}
+		for i := range backfilled {
+			if ctx.Err() != nil {
+				a.removeStreamingQuery(sq.id)
+				close(sq.rows)
+				return nil, ctx.Err()
+			}
+			if sq.deliverLog(ctx, &backfilled[i]) {
+				a.removeStreamingQuery(sq.id)
+				close(sq.rows)
+				return sq, nil
+			}
+		}
+	}
+
+	liveLogs := make(chan types.Log, streamingQueryBuffer)
+	sub, err := a.logs.SubscribeFilterLogs(ctx, query, liveLogs)
+	if err != nil {
+		a.removeStreamingQuery(sq.id)
+		return nil, fmt.Errorf("installing live log filter: %v", err)
+	}
+
+	go func() {
+		defer sub.Unsubscribe()
+		defer a.removeStreamingQuery(sq.id)
+		defer close(sq.rows)
+		for {
+			select {
+			case l := <-liveLogs:
+				if sq.deliverLog(ctx, &l) {
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return sq, nil
+}
+
+// deliverLog resolves l against q's statement, pushing it to q.rows when
+// it matches the WHERE clause. It reports whether q has now delivered
+// LimitClause's full row count, signalling the caller to stop streaming.
+func (q *streamingQuery) deliverLog(ctx context.Context, l *types.Log) (limitReached bool) {
+	row := RowFromLog(l)
+	matched, err := matchesStreamingQuery(ctx, q.statement, row)
+	if err != nil {
+		logger.Debug("streaming query evaluation failed", "query_id", q.id, "err", err)
+		return false
+	}
+	if !matched {
+		return false
+	}
+	logger.Debug("streaming query matched", "query_id", q.id, "block", l.BlockNumber, "tx_hash", l.TxHash)
+	select {
+	case q.rows <- row:
+	default:
+		// subscriber is not keeping up, drop the row rather than block
+	}
+	if q.remaining == nil {
+		return false
+	}
+	*q.remaining--
+	return *q.remaining <= 0
+}
+
+// RowFromLog builds the Row a streaming query's WHERE predicate sees for
+// a single log, keyed the same way LogAttributeProvider resolves log.*
+// attributes so existing predicates evaluate unchanged against a live
+// filter match.
+func RowFromLog(l *types.Log) Row {
+	row := Row{
+		"log.address": NewBytesValue(l.Address.Bytes()),
+		"log.data":    NewBytesValue(l.Data),
+		"log.index":   NewIntValue(new(big.Int).SetUint64(uint64(l.Index))),
+		"log.removed": NewBoolValue(l.Removed),
+	}
+	for i, topic := range l.Topics {
+		row[fmt.Sprintf("log.topics.%d", i)] = NewBytesValue(topic[:])
+	}
+	return row
+}
@@ -0,0 +1,99 @@
+package alerter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSubscription is a no-op ethereum.Subscription that never errors or
+// closes, just enough for RunLiveQuery to install a live filter in tests.
+type fakeSubscription struct {
+	err chan error
+}
+
+func (s *fakeSubscription) Unsubscribe() {}
+func (s *fakeSubscription) Err() <-chan error {
+	return s.err
+}
+
+// fakeLogSource records the last FilterQuery it was asked to subscribe,
+// so a test can assert what address a resolved FROM clause ended up
+// filtering on.
+type fakeLogSource struct {
+	lastQuery ethereum.FilterQuery
+}
+
+func (s *fakeLogSource) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (s *fakeLogSource) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	s.lastQuery = query
+	return &fakeSubscription{err: make(chan error)}, nil
+}
+
+// TestRunLiveQueryResolvesViewFromSource reproduces subscribing to a query
+// whose FROM clause names a registered view: without resolving the FROM
+// clause first, filterQueryFor would dereference a nil FromSource.Address
+// and panic.
+func TestRunLiveQueryResolvesViewFromSource(t *testing.T) {
+	a := NewAlerter(&Config{}, rawdb.NewMemoryDatabase())
+	_, err := a.RegisterView("create view myview as select msg.value from 0x0000000000000000000000000000000000000042")
+	assert.NoError(t, err)
+
+	logs := &fakeLogSource{}
+	a.SetLiveSource(logs, nil, nil)
+
+	parser, err := NewParser(NewLexer("select msg.value from myview"))
+	assert.NoError(t, err)
+	statement, err := parser.ParseSelect()
+	assert.NoError(t, err)
+
+	sq, err := a.RunLiveQuery(context.Background(), statement)
+	assert.NoError(t, err)
+	assert.NotNil(t, sq)
+	assert.Equal(t, []common.Address{common.HexToAddress("0x42")}, logs.lastQuery.Addresses)
+}
+
+// TestRunLiveQueryResolvesMultiSourceView ensures a view with more than
+// one FROM source contributes every one of its addresses to the
+// installed filter, not just the first.
+func TestRunLiveQueryResolvesMultiSourceView(t *testing.T) {
+	a := NewAlerter(&Config{}, rawdb.NewMemoryDatabase())
+	_, err := a.RegisterView("create view myview as select msg.value from 0x0000000000000000000000000000000000000042, 0x0000000000000000000000000000000000000043")
+	assert.NoError(t, err)
+
+	logs := &fakeLogSource{}
+	a.SetLiveSource(logs, nil, nil)
+
+	parser, err := NewParser(NewLexer("select msg.value from myview"))
+	assert.NoError(t, err)
+	statement, err := parser.ParseSelect()
+	assert.NoError(t, err)
+
+	_, err = a.RunLiveQuery(context.Background(), statement)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []common.Address{
+		common.HexToAddress("0x42"),
+		common.HexToAddress("0x43"),
+	}, logs.lastQuery.Addresses)
+}
+
+func TestRunLiveQueryUndefinedSourceErrors(t *testing.T) {
+	a := NewAlerter(&Config{}, rawdb.NewMemoryDatabase())
+	a.SetLiveSource(&fakeLogSource{}, nil, nil)
+
+	parser, err := NewParser(NewLexer("select msg.value from noview"))
+	assert.NoError(t, err)
+	statement, err := parser.ParseSelect()
+	assert.NoError(t, err)
+
+	_, err = a.RunLiveQuery(context.Background(), statement)
+	assert.Error(t, err)
+}
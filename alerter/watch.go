@@ -0,0 +1,156 @@
+package alerter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// WatchConfig tunes Alerter.Watch's re-arm policy
+type WatchConfig struct {
+	// RearmAfter, when positive, lets a predicate that has stayed true
+	// refire after this long even though it never returned to false in
+	// between, mirroring Alertmanager's repeat_interval. Zero (the
+	// default) means a watch only ever refires after observing pred
+	// false again: plain rising-edge detection.
+	RearmAfter time.Duration `yaml:"rearm_after"`
+}
+
+// WatchedPredicate is a Predicate registered via Alerter.Watch, together
+// with the alert it fires on a rising edge (false -> true) and the
+// edge-detection state HandleNewHead maintains for it
+type WatchedPredicate struct {
+	ID      string
+	Pred    Predicate
+	Subject string
+	// Template, when non-empty, is parsed as a text/template and
+	// rendered against a watchTemplateData to build the alert message;
+	// otherwise the message defaults to Pred's own String() form.
+	Template string
+
+	mu        sync.Mutex
+	wasTrue   bool
+	lastFired time.Time
+}
+
+// watchTemplateData is rendered against Template to build a fired watch's
+// alert message
+type watchTemplateData struct {
+	Predicate   string
+	BlockNumber *int64
+}
+
+// Watch registers pred to be re-evaluated against every new chain head
+// (see HandleNewHead), calling SendAlert(subject, <rendered message>) on
+// each rising-edge (false -> true) transition. A predicate that stays
+// true does not refire until it is next observed false, unless
+// Config.Watch.RearmAfter is set. It returns the id later passed to
+// Unwatch.
+func (a *Alerter) Watch(pred Predicate, subject string, messageTemplate string) string {
+	w := &WatchedPredicate{
+		ID:       newQueryID(),
+		Pred:     pred,
+		Subject:  subject,
+		Template: messageTemplate,
+	}
+	a.watchesMu.Lock()
+	a.watches[w.ID] = w
+	a.watchesMu.Unlock()
+	logger.Info("registered watch", "watch_id", w.ID, "predicate", fmt.Sprintf("%v", pred))
+	return w.ID
+}
+
+// Unwatch removes a predicate registered by Watch, returning false if id
+// was not found
+func (a *Alerter) Unwatch(id string) bool {
+	a.watchesMu.Lock()
+	defer a.watchesMu.Unlock()
+	if _, ok := a.watches[id]; !ok {
+		return false
+	}
+	delete(a.watches, id)
+	return true
+}
+
+// HandleNewHead re-evaluates every watched predicate against env (whose
+// BlockNumber, Chain and Resolver should reflect the new head, the same
+// way HandleBlock's caller supplies a Row-bearing Env for streaming
+// queries), firing SendAlert for each one that transitions from false to
+// true. ctx is checked between predicates so a slow/cancelled caller
+// doesn't block on the rest.
+func (a *Alerter) HandleNewHead(ctx context.Context, env *Env) {
+	a.watchesMu.Lock()
+	watches := make([]*WatchedPredicate, 0, len(a.watches))
+	for _, w := range a.watches {
+		watches = append(watches, w)
+	}
+	a.watchesMu.Unlock()
+
+	for _, w := range watches {
+		if ctx.Err() != nil {
+			return
+		}
+		matched, err := w.Pred.ExecuteBool(ctx, env)
+		if err != nil {
+			logger.Warn("watch predicate evaluation failed", "watch_id", w.ID, "err", err)
+			continue
+		}
+		a.fireWatch(ctx, w, matched, env)
+	}
+}
+
+// fireWatch applies w's rising-edge/re-arm logic to the latest evaluation
+// result matched, sending an alert through SendAlert when it decides to fire
+func (a *Alerter) fireWatch(ctx context.Context, w *WatchedPredicate, matched bool, env *Env) {
+	w.mu.Lock()
+	fire := matched && (!w.wasTrue || a.rearmed(w))
+	w.wasTrue = matched
+	if fire {
+		w.lastFired = time.Now()
+	}
+	w.mu.Unlock()
+
+	if !fire {
+		return
+	}
+	message := w.renderMessage(env)
+	if _, err := a.SendAlert(ctx, w.Subject, message); err != nil {
+		logger.Warn("failed to deliver watch alert", "watch_id", w.ID, "err", err)
+	}
+}
+
+// rearmed reports whether w, already true since its last fire, is due to
+// refire under Config.Watch.RearmAfter. Callers must hold w.mu.
+func (a *Alerter) rearmed(w *WatchedPredicate) bool {
+	rearmAfter := a.config.Watch.RearmAfter
+	return rearmAfter > 0 && time.Since(w.lastFired) >= rearmAfter
+}
+
+// renderMessage builds the alert message for a fired watch: Template
+// rendered against env, or Pred's own String() form when Template is empty
+// or fails to render
+func (w *WatchedPredicate) renderMessage(env *Env) string {
+	fallback := fmt.Sprintf("%v", w.Pred)
+	if w.Template == "" {
+		return fallback
+	}
+	tmpl, err := template.New(w.ID).Parse(w.Template)
+	if err != nil {
+		logger.Warn("invalid watch message template", "watch_id", w.ID, "err", err)
+		return fallback
+	}
+	data := watchTemplateData{Predicate: fallback}
+	if env.BlockNumber != nil {
+		blockNumber := env.BlockNumber.Int64()
+		data.BlockNumber = &blockNumber
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		logger.Warn("failed to render watch message template", "watch_id", w.ID, "err", err)
+		return fallback
+	}
+	return buf.String()
+}
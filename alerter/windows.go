@@ -0,0 +1,113 @@
+package alerter
+
+import (
+	"context"
+)
+
+// windowBuffer is a fixed-capacity ring buffer of the raw Values fed into
+// one partition of a window function, holding at most Preceding+1 rows
+// (the current row plus up to Preceding before it).
+type windowBuffer struct {
+	values   []Value
+	capacity int
+	next     int
+	full     bool
+}
+
+func newWindowBuffer(capacity int) *windowBuffer {
+	return &windowBuffer{values: make([]Value, capacity), capacity: capacity}
+}
+
+// push appends value to the buffer, evicting the oldest entry once the
+// buffer is at capacity.
+func (b *windowBuffer) push(value Value) {
+	b.values[b.next] = value
+	b.next = (b.next + 1) % b.capacity
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// items returns the buffer's current contents; order does not matter
+// since every registered Aggregator folds its inputs independent of order.
+func (b *windowBuffer) items() []Value {
+	if !b.full {
+		return b.values[:b.next]
+	}
+	return b.values
+}
+
+// WindowExecutor evaluates a single WindowFunctionCall over a streaming
+// sequence of rows, partitioning them the same way GroupByExecutor
+// partitions GROUP BY rows, but keeping only the last Window.Preceding+1
+// rows per partition instead of folding the whole stream. Each call to Add
+// re-derives the aggregate from scratch over the partition's buffered
+// rows, unlike GroupByExecutor, which folds each row into running
+// Aggregators as it arrives.
+type WindowExecutor struct {
+	call       *WindowFunctionCall
+	aggregate  AggregateFunction
+	partitions map[string]*windowBuffer
+}
+
+// NewWindowExecutor returns an executor for call. call's function name
+// must already have been validated as a registered aggregate by
+// NewWindowFunctionCall.
+func NewWindowExecutor(call *WindowFunctionCall) *WindowExecutor {
+	aggregate, _ := LookupAggregate(call.FunctionName)
+	return &WindowExecutor{
+		call:       call,
+		aggregate:  aggregate,
+		partitions: make(map[string]*windowBuffer),
+	}
+}
+
+// Add evaluates call's argument against row, folds it into row's
+// partition window and returns the aggregate's value over that window.
+func (e *WindowExecutor) Add(ctx context.Context, env *Env, row Row) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key, err := e.partitionKey(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	buffer, exists := e.partitions[key]
+	if !exists {
+		buffer = newWindowBuffer(int(e.call.Window.Preceding) + 1)
+		e.partitions[key] = buffer
+	}
+
+	value, err := e.call.Argument.Execute(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	buffer.push(value)
+
+	aggregator := e.aggregate.New()
+	for _, item := range buffer.items() {
+		if err := aggregator.Add(item); err != nil {
+			return nil, err
+		}
+	}
+	return aggregator.Result()
+}
+
+// partitionKey evaluates the window's PARTITION BY expressions against
+// env's current row, returning the empty key when there is none (a single
+// window spanning the whole stream).
+func (e *WindowExecutor) partitionKey(ctx context.Context, env *Env) (string, error) {
+	if len(e.call.Window.PartitionBy) == 0 {
+		return "", nil
+	}
+	values := make([]Value, len(e.call.Window.PartitionBy))
+	for i, expr := range e.call.Window.PartitionBy {
+		value, err := expr.Execute(ctx, env)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	return serializeValues(values), nil
+}
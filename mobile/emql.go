@@ -0,0 +1,302 @@
+// Package mobile wraps the alerter package's EMQL query language in a
+// gomobile-compatible API, following the pattern used by go-ethereum's
+// own Android/iOS bindings: no *big.Int or interface{} in exported
+// signatures, addresses as hex strings, and custom append-only
+// collections (Strings, Logs) in place of the slice types gomobile cannot
+// bind directly.
+package mobile
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/alerter"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Strings is a gomobile-friendly, append-only collection of strings,
+// used in place of []string (e.g. a log's topics).
+type Strings struct {
+	values []string
+}
+
+// NewStrings returns an empty Strings collection.
+func NewStrings() *Strings {
+	return &Strings{}
+}
+
+// Append adds value to the end of s.
+func (s *Strings) Append(value string) {
+	s.values = append(s.values, value)
+}
+
+// Size returns the number of values in s.
+func (s *Strings) Size() int {
+	return len(s.values)
+}
+
+// Get returns the value at index.
+func (s *Strings) Get(index int) (string, error) {
+	if index < 0 || index >= len(s.values) {
+		return "", fmt.Errorf("index %d out of range: %d values", index, len(s.values))
+	}
+	return s.values[index], nil
+}
+
+// SelectStatement is a gomobile-friendly handle around a parsed EMQL
+// SELECT statement.
+type SelectStatement struct {
+	stmt *alerter.SelectStatement
+}
+
+// ParseEMQL parses a raw EMQL SELECT statement, returning a wrapper a
+// mobile app can evaluate through Select.
+func ParseEMQL(query string) (*SelectStatement, error) {
+	parser, err := alerter.NewParser(alerter.NewLexer(query))
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := parser.ParseSelect()
+	if err != nil {
+		return nil, err
+	}
+	return &SelectStatement{stmt: stmt}, nil
+}
+
+// FromAddresses returns every address named by the statement's FROM
+// clause, as "0x"-prefixed hex strings. Sources without a literal address
+// (a ?name parameter, a subquery, a CTE or a view) are skipped.
+func (s *SelectStatement) FromAddresses() *Strings {
+	addresses := NewStrings()
+	if s.stmt.From != nil {
+		for _, source := range s.stmt.From.Sources {
+			if source.Address == nil {
+				continue
+			}
+			addresses.Append(common.BigToAddress(source.Address).Hex())
+		}
+	}
+	return addresses
+}
+
+// Limit returns the statement's LIMIT clause, or -1 if it has none.
+func (s *SelectStatement) Limit() int64 {
+	if s.stmt.Limit == nil {
+		return -1
+	}
+	return *s.stmt.Limit
+}
+
+// Since returns the statement's SINCE clause as an explicit block number,
+// or -1 if it has none or is a timestamp/duration form that still needs
+// server-side resolution (see alerter.ResolveBlockRef).
+func (s *SelectStatement) Since() int64 {
+	return blockRefNumber(s.stmt.Since)
+}
+
+// Until is the UNTIL equivalent of Since.
+func (s *SelectStatement) Until() int64 {
+	return blockRefNumber(s.stmt.Until)
+}
+
+func blockRefNumber(ref *alerter.BlockRef) int64 {
+	if ref == nil || ref.NeedsResolution() {
+		return -1
+	}
+	return ref.BlockNum
+}
+
+// NodeClient is the chain access a mobile light client gives to
+// MobileEnv. Unlike alerter.ChainState, addresses and integers cross this
+// boundary as hex/decimal strings rather than common.Address/*big.Int, so
+// gomobile bindings can implement it directly.
+type NodeClient interface {
+	// CodeAt returns the "0x"-prefixed hex contract code at address, as
+	// of blockNumber (a decimal string, or "" for the latest block).
+	CodeAt(address string, blockNumber string) (string, error)
+	// BalanceAt returns address's balance in wei as a decimal string, as
+	// of blockNumber (a decimal string, or "" for the latest block).
+	BalanceAt(address string, blockNumber string) (string, error)
+}
+
+// MobileEnv wraps an alerter.Env around a NodeClient, letting a mobile
+// app evaluate EMQL queries directly against a light client. The client
+// serves as the Env's chain state, which backs the IS CONTRACT/EOA/ERC20/
+// ERC721 checks; the Env has no attribute resolver, so an attribute that
+// is not a column of the log's row (e.g. contract.*) fails to evaluate.
+type MobileEnv struct {
+	env    *alerter.Env
+	client NodeClient
+}
+
+// NewMobileEnv returns a MobileEnv whose chain state is read through
+// client.
+func NewMobileEnv(client NodeClient) *MobileEnv {
+	m := &MobileEnv{client: client}
+	m.env = &alerter.Env{Chain: m}
+	return m
+}
+
+// CodeAt implements alerter.ChainState, adapting between common.Address/
+// *big.Int and the hex/decimal strings NodeClient uses.
+func (m *MobileEnv) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	code, err := m.client.CodeAt(account.Hex(), blockNumberString(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	return common.FromHex(code), nil
+}
+
+// BalanceAt is the balance equivalent of CodeAt.
+func (m *MobileEnv) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	decimal, err := m.client.BalanceAt(account.Hex(), blockNumberString(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := new(big.Int).SetString(decimal, 10)
+	if !ok {
+		return nil, fmt.Errorf("balance %q for %s is not a decimal integer", decimal, account.Hex())
+	}
+	return balance, nil
+}
+
+func blockNumberString(blockNumber *big.Int) string {
+	if blockNumber == nil {
+		return ""
+	}
+	return blockNumber.String()
+}
+
+// Log is a gomobile-friendly representation of a single Ethereum log, as
+// surfaced by a light client. Address is a "0x"-prefixed hex string;
+// Topics holds "0x"-prefixed 32-byte hex strings in log order.
+type Log struct {
+	Address string
+	Data    []byte
+	Topics  *Strings
+	Index   int64
+	Removed bool
+}
+
+// NewLog constructs a Log from its gomobile-friendly fields.
+func NewLog(address string, data []byte, topics *Strings, index int64, removed bool) *Log {
+	return &Log{Address: address, Data: data, Topics: topics, Index: index, Removed: removed}
+}
+
+func (l *Log) toRow() (alerter.Row, error) {
+	if !common.IsHexAddress(l.Address) {
+		return nil, fmt.Errorf("invalid log address %q", l.Address)
+	}
+	row := alerter.Row{
+		"log.address": alerter.NewBytesValue(common.HexToAddress(l.Address).Bytes()),
+		"log.data":    alerter.NewBytesValue(l.Data),
+		"log.index":   alerter.NewIntValue(big.NewInt(l.Index)),
+		"log.removed": alerter.NewBoolValue(l.Removed),
+	}
+	if l.Topics != nil {
+		for i, topic := range l.Topics.values {
+			row[fmt.Sprintf("log.topics.%d", i)] = alerter.NewBytesValue(common.HexToHash(topic).Bytes())
+		}
+	}
+	return row, nil
+}
+
+// Logs is a gomobile-friendly, append-only collection of Log, used in
+// place of []*Log.
+type Logs struct {
+	logs []*Log
+}
+
+// NewLogs returns an empty Logs collection.
+func NewLogs() *Logs {
+	return &Logs{}
+}
+
+// Append adds log to the end of ls.
+func (ls *Logs) Append(log *Log) {
+	ls.logs = append(ls.logs, log)
+}
+
+// Size returns the number of logs in ls.
+func (ls *Logs) Size() int {
+	return len(ls.logs)
+}
+
+// QueryResult is the gomobile-friendly iterator returned by Select,
+// exposing each matched row's selected columns as strings/bytes rather
+// than the alerter package's Value interface.
+type QueryResult struct {
+	rows []alerter.Row
+}
+
+// Size returns the number of matched rows.
+func (r *QueryResult) Size() int {
+	return len(r.rows)
+}
+
+// GetString returns row's column, converted through Value.ToString.
+func (r *QueryResult) GetString(row int, column string) (string, error) {
+	value, err := r.value(row, column)
+	if err != nil {
+		return "", err
+	}
+	return value.ToString(), nil
+}
+
+// GetBytes returns row's column, converted through Value.ToBytes.
+func (r *QueryResult) GetBytes(row int, column string) ([]byte, error) {
+	value, err := r.value(row, column)
+	if err != nil {
+		return nil, err
+	}
+	return value.ToBytes(), nil
+}
+
+func (r *QueryResult) value(row int, column string) (alerter.Value, error) {
+	if row < 0 || row >= len(r.rows) {
+		return nil, fmt.Errorf("row index %d out of range: %d rows", row, len(r.rows))
+	}
+	value, ok := r.rows[row][column]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q", column)
+	}
+	return value, nil
+}
+
+// Select evaluates stmt's WHERE clause (if any) against every log in
+// logs, projecting stmt's Selected expressions for every match into the
+// returned QueryResult. env backs the chain state access of those
+// expressions' IS checks.
+func Select(stmt *SelectStatement, env *MobileEnv, logs *Logs) (*QueryResult, error) {
+	result := &QueryResult{}
+	for _, l := range logs.logs {
+		row, err := l.toRow()
+		if err != nil {
+			return nil, err
+		}
+		env.env.Row = row
+
+		matched := true
+		if stmt.stmt.Where != nil {
+			matched, err = stmt.stmt.Where.ExecuteBool(context.Background(), env.env)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		projected := make(alerter.Row, len(stmt.stmt.Selected))
+		for _, expression := range stmt.stmt.Selected {
+			value, err := expression.Execute(context.Background(), env.env)
+			if err != nil {
+				return nil, err
+			}
+			projected[expression.String()] = value
+		}
+		result.rows = append(result.rows, projected)
+	}
+	return result, nil
+}